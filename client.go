@@ -5,23 +5,40 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"strings"
 
 	internalJSON "github.com/jbrekelmans/go-graphql/json"
 )
 
+const (
+	// MediaTypeJSON is the media type of JSON-encoded GraphQL requests, and the legacy media type of GraphQL responses.
+	MediaTypeJSON = "application/json"
+
+	// MediaTypeGraphQLResponseJSON is the media type of GraphQL responses defined by the GraphQL-over-HTTP specification.
+	// See https://graphql.github.io/graphql-over-http/draft/.
+	MediaTypeGraphQLResponseJSON = "application/graphql-response+json"
+)
+
+// acceptHeader prefers MediaTypeGraphQLResponseJSON but still accepts servers that only support MediaTypeJSON.
+const acceptHeader = MediaTypeGraphQLResponseJSON + ", " + MediaTypeJSON + ";q=0.9"
+
 // Client is a client for talking to a GraphQL server using HTTP with JSON-encoded requests/responses.
 type Client struct {
-	url        string
-	httpClient *http.Client
+	url           string
+	httpClient    *http.Client
+	options       options
+	selectionSets selectionSetCache
 }
 
 // NewClient constructs a client.
-func NewClient(url string, httpClient *http.Client) *Client {
+func NewClient(url string, httpClient *http.Client, opts ...Option) *Client {
 	c := &Client{
 		url:        url,
 		httpClient: httpClient,
+		options:    newOptions(opts),
 	}
 	if c.httpClient == nil {
 		c.httpClient = http.DefaultClient
@@ -29,40 +46,78 @@ func NewClient(url string, httpClient *http.Client) *Client {
 	return c
 }
 
-func (c *Client) doRequest(ctx context.Context, operationType string, q any, variables map[string]any) (resp *http.Response, err error) {
-	var queryBuilder queryBuilder
-	if err = queryBuilder.operation(operationType, q, variables); err != nil {
+func (c *Client) doRequest(ctx context.Context, operationType string, q any, variables any) (resp *http.Response, err error) {
+	vars, err := newVariables(variables)
+	if err != nil {
+		return
+	}
+	operation, err := c.selectionSets.operation(&c.options, operationType, q, vars)
+	if err != nil {
 		return
 	}
-	operation := queryBuilder.String()
+	return c.do(ctx, operation, "", vars, func(data []byte) error {
+		return internalJSON.Unmarshal(data, q, c.options.jsonOptions()...)
+	})
+}
+
+// do sends the GraphQL document operation to the server and calls decodeData with the "data" property of the response, if
+// the response has data.
+func (c *Client) do(ctx context.Context, operation, operationName string, vars []variable,
+	decodeData func(data []byte) error) (resp *http.Response, err error) {
 	// Add operation to error
 	defer func() {
 		err = setErrorOperation(err, operation)
 	}()
-	var reqBody bytes.Buffer
-	if err = json.NewEncoder(&reqBody).Encode(request{
-		Query:     operation,
-		Variables: variables,
-	}); err != nil {
+	var variablesJSON json.RawMessage
+	if len(vars) > 0 {
+		if variablesJSON, err = encodeVariables(vars, c.options.scalars); err != nil {
+			return
+		}
+	}
+	reqBody, err := json.Marshal(request{
+		Query:         operation,
+		OperationName: operationName,
+		Variables:     variablesJSON,
+	})
+	if err != nil {
 		return
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, &reqBody)
+	var contentEncoding ContentEncoding
+	if c.options.requestCompression != "" && len(reqBody) >= c.options.requestCompressionMinSize {
+		contentEncoding = c.options.requestCompression
+		if reqBody, err = compress(contentEncoding, reqBody); err != nil {
+			err = fmt.Errorf(`error compressing request body: %w`, err)
+			return
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(reqBody))
 	if err != nil {
 		return
 	}
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", MediaTypeJSON)
+	req.Header.Add("Accept", acceptHeader)
+	if contentEncoding != "" {
+		req.Header.Add("Content-Encoding", string(contentEncoding))
+	}
 	resp, err = c.httpClient.Do(req)
 	if err != nil {
 		return
 	}
-	respBodyBytes, err := io.ReadAll(resp.Body)
+	respBodyBytes, err := readResponseBody(resp)
 	_ = resp.Body.Close()
 	if err != nil {
 		err = fmt.Errorf(`error reading body of %d-response: %w`, resp.StatusCode, err)
 		return
 	}
+	mediaType := ResponseMediaType(resp)
+	success := isSuccessStatus(mediaType, resp.StatusCode)
 	var respBody response
 	if err = json.NewDecoder(bytes.NewReader(respBodyBytes)).Decode(&respBody); err != nil {
+		if !success {
+			// The body is not a GraphQL response, for example because it was produced by a proxy.
+			err = fmt.Errorf(`response has non-success status %d: %s`, resp.StatusCode, string(respBodyBytes))
+			return
+		}
 		err = fmt.Errorf(`error unmarshaling body of %d-response: %s (%w)`, resp.StatusCode,
 			string(respBodyBytes), err)
 		return
@@ -71,16 +126,18 @@ func (c *Client) doRequest(ctx context.Context, operationType string, q any, var
 	defer func() {
 		err = setErrorItems(err, respBody.Errors)
 	}()
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(`response has non-success status %d: %s`, resp.StatusCode, string(respBodyBytes))
-		return
-	}
-	if respBody.Data != nil {
-		if unmarshalErr := internalJSON.Unmarshal(*respBody.Data, q); unmarshalErr != nil {
+	// With application/graphql-response+json a well-formed response body is a GraphQL response regardless of the status, so
+	// (partial) data is decoded even if the status indicates an error.
+	if respBody.Data != nil && (success || mediaType == MediaTypeGraphQLResponseJSON) {
+		if unmarshalErr := decodeData(*respBody.Data); unmarshalErr != nil {
 			err = fmt.Errorf(`error decoding data of %d-response: %w`, resp.StatusCode, unmarshalErr)
 			return
 		}
 	}
+	if !success {
+		err = fmt.Errorf(`response has non-success status %d: %s`, resp.StatusCode, string(respBodyBytes))
+		return
+	}
 	if len(respBody.Errors) > 0 {
 		errorsJSON, _ := json.Marshal(respBody.Errors)
 		err = fmt.Errorf(`%d-response with errors: %s`, resp.StatusCode, string(errorsJSON))
@@ -88,9 +145,33 @@ func (c *Client) doRequest(ctx context.Context, operationType string, q any, var
 	return
 }
 
+// ResponseMediaType returns the media type of the body of resp as per its Content-Type header, in lower case and without
+// parameters. For example, this returns MediaTypeGraphQLResponseJSON if the server implements the GraphQL-over-HTTP
+// specification. Returns an empty string if resp is nil or its Content-Type header is absent or invalid.
+func ResponseMediaType(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(mediaType)
+}
+
+// isSuccessStatus returns true if statusCode indicates success for a response with the given media type.
+// The GraphQL-over-HTTP specification allows any 2xx status for application/graphql-response+json, and uses 4xx statuses for
+// request errors (such as a document that fails validation). Legacy application/json servers always use status 200.
+func isSuccessStatus(mediaType string, statusCode int) bool {
+	if mediaType == MediaTypeGraphQLResponseJSON {
+		return statusCode/100 == 2
+	}
+	return statusCode == http.StatusOK
+}
+
 // Mutate does a mutation operation on the GraphQL server.
 // See Query for more information.
-func (c *Client) Mutate(ctx context.Context, m any, variables map[string]any) (*http.Response, error) {
+func (c *Client) Mutate(ctx context.Context, m any, variables any) (*http.Response, error) {
 	return c.doRequest(ctx, "mutation", m, variables)
 }
 
@@ -98,6 +179,18 @@ func (c *Client) Mutate(ctx context.Context, m any, variables map[string]any) (*
 // If the HTTP response status and headers were received successfully then returns a non-nil *http.Response that reflects the status and
 // headers. The body of the returned HTTP response is always closed.
 //
+// The selection set of the query is constructed from the type of q, and cached by the client for subsequent calls with the
+// same type.
+// variables is nil, a map with string keys or a (pointer to a) struct. The fields of a struct define variables in field
+// order, and their graphql tags can declare the name, GraphQL type and default value of each variable, e.g.
+// `graphql:"first:Int=20"`.
+//
+// Query prefers responses of media type application/graphql-response+json as per the GraphQL-over-HTTP specification, but
+// also accepts application/json. Use ResponseMediaType to determine which media type the server chose.
+// If the server responds with application/graphql-response+json then any 2xx status indicates success, and a non-2xx status
+// with a well-formed body (typically a 4xx request error) still has its errors and, if present, data decoded.
+// If the server responds with application/json then any status other than 200 is an error.
+//
 // The returned error will be of type *Error, unless an error occurs formatting the GraphQL query/mutation/operation.
 // If the GraphQL response was completely received and parsed, and contains GraphQL-level errors,
 // these errors are reflected in the returned (*Error).Errors.
@@ -117,13 +210,53 @@ func (c *Client) Mutate(ctx context.Context, m any, variables map[string]any) (*
 //   - the underlying connnection when reading the HTTP response body.
 //
 // See https://spec.graphql.org/.
-func (c *Client) Query(ctx context.Context, q any, variables map[string]any) (*http.Response, error) {
+func (c *Client) Query(ctx context.Context, q any, variables any) (*http.Response, error) {
 	return c.doRequest(ctx, "query", q, variables)
 }
 
+// Exec executes document on the GraphQL server. Unlike Query and Mutate, document is sent as-is, so it can contain
+// anything that cannot be expressed with Go structs, such as named fragments, directives and multiple operations.
+// operationName selects the operation to execute if document contains multiple operations, and should be empty otherwise.
+//
+// The "data" property of the response is unmarshaled into out, which must be one of the following:
+//  1. a pointer to a struct whose fields follow the same conventions as the structs passed to Query (i.e. graphql tags);
+//  2. a *json.RawMessage, which receives the data verbatim;
+//  3. any other value that "encoding/json".Unmarshal accepts, such as a *map[string]any; -or
+//  4. nil, in which case the data is discarded.
+//
+// The returned response and error follow the same semantics as Query.
+func (c *Client) Exec(ctx context.Context, document, operationName string, variables any,
+	out any) (*http.Response, error) {
+	vars, err := newVariables(variables)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, document, operationName, vars, func(data []byte) error {
+		return decodeExecData(data, out, &c.options)
+	})
+}
+
+func decodeExecData(data []byte, out any, o *options) error {
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *json.RawMessage:
+		if out == nil {
+			return fmt.Errorf(`out is nil`)
+		}
+		*out = append((*out)[:0], data...)
+		return nil
+	}
+	if t := reflect.TypeOf(out); t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct {
+		return internalJSON.Unmarshal(data, out, o.jsonOptions()...)
+	}
+	return json.Unmarshal(data, out)
+}
+
 type request struct {
-	Query     string         `json:"query"`
-	Variables map[string]any `json:"variables,omitempty"`
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
 }
 
 type response struct {
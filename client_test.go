@@ -1,6 +1,7 @@
 package graphql
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -41,7 +42,7 @@ func Test_Client(t *testing.T) {
 		t.Run("ErrorMarshalingRequestBody", func(t *testing.T) {
 			c := setupTestCase(0, nil, nil)
 			var q struct {
-				Name string
+				Name string `graphql:"name(test: $test)"`
 			}
 			_, err := c.doRequest(context.Background(), "query", &q, map[string]interface{}{
 				"test": jsonMarshalBomb{},
@@ -49,7 +50,7 @@ func Test_Client(t *testing.T) {
 			assert.ErrorContains(t, err, "jsonMarshalBomb: boom!")
 			if assert.IsType(t, &Error{}, err) {
 				err2 := err.(*Error)
-				assert.Equal(t, "query($test:jsonMarshalBomb!){name}", err2.Operation)
+				assert.Equal(t, "query($test:jsonMarshalBomb!){name(test: $test)}", err2.Operation)
 			}
 		})
 		t.Run("ErrorNewRequest", func(t *testing.T) {
@@ -184,6 +185,205 @@ func Test_Client(t *testing.T) {
 			assert.NoError(t, err)
 			assert.Equal(t, "hi", q.Name)
 		})
+		t.Run("AcceptHeader", func(t *testing.T) {
+			c := setupTestCase(200, []byte(`{"data":{"name":"hi"}}`), nil)
+			var q struct {
+				Name string
+			}
+			_, err := c.doRequest(context.Background(), "query", &q, nil)
+			if assert.NoError(t, err) {
+				req := c.httpClient.Transport.(*testTransport).Req
+				assert.Equal(t, "application/graphql-response+json, application/json;q=0.9", req.Header.Get("Accept"))
+				assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+			}
+		})
+		t.Run("NonSuccessStatusWithInvalidBody", func(t *testing.T) {
+			c := setupTestCase(502, []byte(`<html>Bad Gateway</html>`), nil)
+			var q struct {
+				Name string
+			}
+			_, err := c.doRequest(context.Background(), "query", &q, nil)
+			assert.ErrorContains(t, err, "response has non-success status 502: <html>Bad Gateway</html>")
+		})
+		t.Run("RequestCompression", func(t *testing.T) {
+			c := setupTestCase(200, []byte(`{"data":{"name":"hi"}}`), nil)
+			c.options = newOptions([]Option{WithRequestCompression(ContentEncodingGzip, 0)})
+			var q struct {
+				Name string
+			}
+			_, err := c.doRequest(context.Background(), "query", &q, nil)
+			if assert.NoError(t, err) {
+				req := c.httpClient.Transport.(*testTransport).Req
+				assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+				gzipReader, err := gzip.NewReader(req.Body)
+				if assert.NoError(t, err) {
+					reqBody, err := io.ReadAll(gzipReader)
+					if assert.NoError(t, err) {
+						assert.Equal(t, `{"query":"query{name}"}`, string(reqBody))
+					}
+				}
+			}
+		})
+		t.Run("RequestCompressionBelowMinSize", func(t *testing.T) {
+			c := setupTestCase(200, []byte(`{"data":{"name":"hi"}}`), nil)
+			c.options = newOptions([]Option{WithRequestCompression(ContentEncodingDeflate, 1024)})
+			var q struct {
+				Name string
+			}
+			_, err := c.doRequest(context.Background(), "query", &q, nil)
+			if assert.NoError(t, err) {
+				req := c.httpClient.Transport.(*testTransport).Req
+				assert.Equal(t, "", req.Header.Get("Content-Encoding"))
+			}
+		})
+		t.Run("CompressedResponse", func(t *testing.T) {
+			respBody, err := compress(ContentEncodingDeflate, []byte(`{"data":{"name":"hi"}}`))
+			if !assert.NoError(t, err) {
+				return
+			}
+			c := setupTestCase(200, respBody, nil)
+			c.httpClient.Transport.(*testTransport).ContentEncoding = "deflate"
+			var q struct {
+				Name string
+			}
+			_, err = c.doRequest(context.Background(), "query", &q, nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "hi", q.Name)
+			}
+		})
+		t.Run("GraphQLResponseJSON", func(t *testing.T) {
+			setupGraphQLResponseTestCase := func(statusCode int, respBody string) *Client {
+				c := setupTestCase(statusCode, []byte(respBody), nil)
+				c.httpClient.Transport.(*testTransport).ContentType = "application/graphql-response+json; charset=utf-8"
+				return c
+			}
+			t.Run("2xxIsSuccess", func(t *testing.T) {
+				c := setupGraphQLResponseTestCase(202, `{"data":{"name":"hi"}}`)
+				var q struct {
+					Name string
+				}
+				resp, err := c.doRequest(context.Background(), "query", &q, nil)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "hi", q.Name)
+					assert.Equal(t, MediaTypeGraphQLResponseJSON, ResponseMediaType(resp))
+				}
+			})
+			t.Run("RequestError", func(t *testing.T) {
+				c := setupGraphQLResponseTestCase(400, `{"errors":[{"message":"msg3"}]}`)
+				var q struct {
+					Name string
+				}
+				_, err := c.doRequest(context.Background(), "query", &q, nil)
+				assert.ErrorContains(t, err, "response has non-success status 400: ")
+				if assert.IsType(t, &Error{}, err) {
+					err2 := err.(*Error)
+					assert.Equal(t, "query{name}", err2.Operation)
+					assert.Equal(t, []ErrorItem{
+						{
+							Message: "msg3",
+							Raw: map[string]json.RawMessage{
+								"message": json.RawMessage(`"msg3"`),
+							},
+						},
+					}, err2.Errors)
+				}
+			})
+			t.Run("DataWithNonSuccessStatus", func(t *testing.T) {
+				c := setupGraphQLResponseTestCase(500, `{"data":{"name":"partial"},"errors":[{"message":"msg4"}]}`)
+				var q struct {
+					Name string
+				}
+				_, err := c.doRequest(context.Background(), "query", &q, nil)
+				assert.ErrorContains(t, err, "response has non-success status 500: ")
+				assert.Equal(t, "partial", q.Name)
+			})
+		})
+	})
+}
+
+func Test_Client_Exec(t *testing.T) {
+	setupTestCase := func(respBody string, opts ...Option) *Client {
+		return NewClient("http://localhost/graphql", &http.Client{
+			Transport: &testTransport{
+				RespBody: []byte(respBody),
+			},
+		}, opts...)
+	}
+	const document = `query A{name}query B($id:ID!){node(id:$id){id}}`
+	t.Run("Struct", func(t *testing.T) {
+		c := setupTestCase(`{"data":{"name":"hi"}}`)
+		var out struct {
+			Name string
+		}
+		_, err := c.Exec(context.Background(), document, "A", nil, &out)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "hi", out.Name)
+			reqBody, err := io.ReadAll(c.httpClient.Transport.(*testTransport).Req.Body)
+			if assert.NoError(t, err) {
+				assert.Equal(t, `{"query":"query A{name}query B($id:ID!){node(id:$id){id}}","operationName":"A"}`, string(reqBody))
+			}
+		}
+	})
+	t.Run("PartialStruct", func(t *testing.T) {
+		const respBody = `{"data":{"node":{"id":"1","name":"Henk","friends":[{"id":"2"}]}}}`
+		var out struct {
+			Node struct {
+				ID string
+			}
+		}
+		c := setupTestCase(respBody)
+		_, err := c.Exec(context.Background(), document, "B", map[string]any{"id": ID{"1"}}, &out)
+		assert.ErrorContains(t, err, `JSON object has property named "name" (at node.name) but no receiver struct has a `+
+			`field mapped to that property`)
+		c = setupTestCase(respBody, WithIgnoreUnknownProperties())
+		_, err = c.Exec(context.Background(), document, "B", map[string]any{"id": ID{"1"}}, &out)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1", out.Node.ID)
+		}
+	})
+	t.Run("Map", func(t *testing.T) {
+		c := setupTestCase(`{"data":{"node":{"id":"1"}}}`)
+		var out map[string]any
+		_, err := c.Exec(context.Background(), document, "B", map[string]any{"id": ID{"1"}}, &out)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]any{"node": map[string]any{"id": "1"}}, out)
+		}
+	})
+	t.Run("RawMessage", func(t *testing.T) {
+		c := setupTestCase(`{"data":{"name":"hi"}}`)
+		var out json.RawMessage
+		_, err := c.Exec(context.Background(), document, "A", nil, &out)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"name":"hi"}`, string(out))
+		}
+	})
+	t.Run("Nil", func(t *testing.T) {
+		c := setupTestCase(`{"data":{"name":"hi"}}`)
+		_, err := c.Exec(context.Background(), document, "A", nil, nil)
+		assert.NoError(t, err)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		c := setupTestCase(`{"errors":[{"message":"msg5"}]}`)
+		var out json.RawMessage
+		_, err := c.Exec(context.Background(), document, "A", nil, &out)
+		assert.ErrorContains(t, err, "200-response with errors: ")
+		if assert.IsType(t, &Error{}, err) {
+			assert.Equal(t, document, err.(*Error).Operation)
+		}
+	})
+}
+
+func Test_ResponseMediaType(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		assert.Equal(t, "", ResponseMediaType(nil))
+	})
+	t.Run("Case2", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{"Content-Type": {"Application/JSON; charset=utf-8"}}}
+		assert.Equal(t, MediaTypeJSON, ResponseMediaType(resp))
+	})
+	t.Run("Case3", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}}
+		assert.Equal(t, "", ResponseMediaType(resp))
 	})
 }
 
@@ -197,7 +397,10 @@ func (j jsonMarshalBomb) MarshalJSON() ([]byte, error) {
 }
 
 type testTransport struct {
+	ContentEncoding string
+	ContentType     string
 	Err             error
+	Req             *http.Request
 	RespBody        []byte
 	RespBodyReadErr error
 	StatusCode      int
@@ -206,6 +409,7 @@ type testTransport struct {
 var _ http.RoundTripper = (*testTransport)(nil)
 
 func (t *testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.Req = req
 	if t.Err != nil {
 		return nil, t.Err
 	}
@@ -226,7 +430,14 @@ func (t *testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		Request:    req,
 		Header:     respHeader,
 	}
-	respHeader.Set("Content-Type", "application/json")
+	contentType := t.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	respHeader.Set("Content-Type", contentType)
+	if t.ContentEncoding != "" {
+		respHeader.Set("Content-Encoding", t.ContentEncoding)
+	}
 	if req.Method != http.MethodHead {
 		resp.Body = io.NopCloser(&testReader{
 			Data: t.RespBody,
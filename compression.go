@@ -0,0 +1,71 @@
+package graphql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ContentEncoding is a value of the Content-Encoding HTTP header.
+type ContentEncoding string
+
+const (
+	// ContentEncodingGzip is the gzip content encoding. See https://www.rfc-editor.org/rfc/rfc1952.
+	ContentEncodingGzip ContentEncoding = "gzip"
+
+	// ContentEncodingDeflate is the deflate content encoding, which (despite its name) uses the zlib format.
+	// See https://www.rfc-editor.org/rfc/rfc1950.
+	ContentEncodingDeflate ContentEncoding = "deflate"
+)
+
+// compress compresses b using encoding.
+func compress(encoding ContentEncoding, b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case ContentEncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case ContentEncodingDeflate:
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf(`unsupported content encoding %#v`, string(encoding))
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readResponseBody reads the body of resp and decompresses it as per the Content-Encoding header.
+// The Content-Encoding header is only present if the http.RoundTripper did not decompress the body transparently, for example
+// if it is an *http.Transport with DisableCompression set to true.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); ContentEncoding(encoding) {
+	case "", "identity":
+	case ContentEncodingGzip:
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	case ContentEncodingDeflate:
+		zlibReader, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zlibReader.Close()
+		r = zlibReader
+	default:
+		return nil, fmt.Errorf(`unsupported content encoding %#v`, encoding)
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,50 @@
+package graphql
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compress(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		_, err := compress("br", []byte("x"))
+		assert.ErrorContains(t, err, `unsupported content encoding "br"`)
+	})
+}
+
+func Test_readResponseBody(t *testing.T) {
+	newResponse := func(contentEncoding string, body []byte) *http.Response {
+		return &http.Response{
+			Header: http.Header{"Content-Encoding": {contentEncoding}},
+			Body:   io.NopCloser(bytes.NewReader(body)),
+		}
+	}
+	t.Run("Gzip", func(t *testing.T) {
+		body, err := compress(ContentEncodingGzip, []byte("hello"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		actual, err := readResponseBody(newResponse("GZIP", body))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "hello", string(actual))
+		}
+	})
+	t.Run("Identity", func(t *testing.T) {
+		actual, err := readResponseBody(newResponse("identity", []byte("hello")))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "hello", string(actual))
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := readResponseBody(newResponse("br", []byte("hello")))
+		assert.ErrorContains(t, err, `unsupported content encoding "br"`)
+	})
+	t.Run("Corrupt", func(t *testing.T) {
+		_, err := readResponseBody(newResponse("gzip", []byte("hello")))
+		assert.Error(t, err)
+	})
+}
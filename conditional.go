@@ -0,0 +1,54 @@
+package graphql
+
+import (
+	"reflect"
+
+	internalJSON "github.com/jbrekelmans/go-graphql/json"
+)
+
+// Conditional wraps the Go value of a field that is selected conditionally, typically by the @include or @skip directive
+// defined with an "include" or "skip" struct tag:
+//
+//	var q struct {
+//		Viewer struct {
+//			Email graphql.Conditional[*string] `include:"withEmail"`
+//		}
+//	}
+//
+// After unmarshaling, Included distinguishes a field that was skipped from a field whose value is null.
+type Conditional[T any] struct {
+	// Value is the value of the field, or the zero value if the field was skipped.
+	Value T
+
+	// Included is true if the field was present in the response (even if its value is null), and false if the field was
+	// skipped.
+	Included bool
+}
+
+var _ internalJSON.ValueWrapper = (*Conditional[int])(nil)
+
+// WrappedValue implements the json.ValueWrapper interface.
+func (c *Conditional[T]) WrappedValue() any {
+	c.Included = true
+	return &c.Value
+}
+
+func (Conditional[T]) conditionalValueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// conditional is implemented by all instantiations of Conditional.
+type conditional interface {
+	conditionalValueType() reflect.Type
+}
+
+var conditionalType = reflect.TypeOf((*conditional)(nil)).Elem()
+
+// conditionalValueTypeOf returns the type of the value wrapped by t if t is an instantiation of Conditional, and t
+// otherwise.
+func conditionalValueTypeOf(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Struct && t.Implements(conditionalType) {
+		return reflect.Zero(t).Interface().(conditional).conditionalValueType()
+	}
+	return t
+}
@@ -0,0 +1,93 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	internalJSON "github.com/jbrekelmans/go-graphql/json"
+)
+
+func Test_Conditional(t *testing.T) {
+	type Query struct {
+		Viewer struct {
+			Login string
+			Email Conditional[*string]   `include:"withEmail"`
+			Bio   Conditional[*string]   `skip:"$noBio"`
+			Repos Conditional[[]struct { // Selection sets of wrapped values are constructed as usual.
+				Name string
+			}] `include:"withEmail"`
+		}
+	}
+	t.Run("ConstructQuery", func(t *testing.T) {
+		actual, err := ConstructQuery(&Query{}, map[string]any{"withEmail": true, "noBio": false})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($noBio:Boolean!,$withEmail:Boolean!){viewer{login,email @include(if: $withEmail),`+
+				`bio @skip(if: $noBio),repos @include(if: $withEmail){name}}}`, actual)
+		}
+	})
+	t.Run("UndefinedVariable", func(t *testing.T) {
+		_, err := ConstructQuery(&Query{}, map[string]any{"noBio": false})
+		assert.EqualError(t, err, `variable $withEmail of @include directive of Go struct field Viewer.Email is not defined`)
+	})
+	t.Run("NonBooleanVariable", func(t *testing.T) {
+		_, err := ConstructQuery(&Query{}, map[string]any{"withEmail": true, "noBio": (*bool)(nil)})
+		assert.EqualError(t, err, `variable $noBio of @skip directive of Go struct field Viewer.Bio must have type Boolean!, `+
+			`but has type Boolean`)
+	})
+	t.Run("Unmarshal", func(t *testing.T) {
+		var q Query
+		err := internalJSON.Unmarshal([]byte(`{"viewer":{"login":"x","email":null,"repos":[{"name":"r"}]}}`), &q)
+		if assert.NoError(t, err) {
+			assert.True(t, q.Viewer.Email.Included)
+			assert.Nil(t, q.Viewer.Email.Value)
+			assert.False(t, q.Viewer.Bio.Included)
+			assert.True(t, q.Viewer.Repos.Included)
+			if assert.Len(t, q.Viewer.Repos.Value, 1) {
+				assert.Equal(t, "r", q.Viewer.Repos.Value[0].Name)
+			}
+		}
+	})
+	t.Run("InlineFragment", func(t *testing.T) {
+		type Query struct {
+			Hero struct {
+				Name  string
+				Droid Conditional[*struct {
+					PrimaryFunction string
+				}] `graphql:"... on Droid" include:"x"`
+			}
+		}
+		actual, err := ConstructQuery(&Query{}, map[string]any{"x": true})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($x:Boolean!){hero{name,... on Droid @include(if: $x){primaryFunction}}}`, actual)
+		}
+		var q Query
+		err = internalJSON.Unmarshal([]byte(`{"hero":{"name":"R2-D2","primaryFunction":"Astromech"}}`), &q)
+		if assert.NoError(t, err) {
+			assert.True(t, q.Hero.Droid.Included)
+			if assert.NotNil(t, q.Hero.Droid.Value) {
+				assert.Equal(t, "Astromech", q.Hero.Droid.Value.PrimaryFunction)
+			}
+		}
+		q = Query{}
+		err = internalJSON.Unmarshal([]byte(`{"hero":{"name":"R2-D2"}}`), &q)
+		if assert.NoError(t, err) {
+			assert.False(t, q.Hero.Droid.Included)
+			assert.Nil(t, q.Hero.Droid.Value)
+		}
+	})
+	t.Run("FragmentSpread", func(t *testing.T) {
+		var q struct {
+			Viewer struct {
+				ID                 string
+				UserFieldsFragment `include:"x"`
+			}
+		}
+		actual, err := ConstructQuery(&q, map[string]any{"x": true})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($x:Boolean!){viewer{id,...UserFields @include(if: $x)}}`+
+				`fragment UserFields on User{name,avatar{...AvatarFields}}`+
+				`fragment AvatarFields on Avatar{url}`, actual)
+		}
+	})
+}
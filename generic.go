@@ -0,0 +1,22 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+)
+
+// Query is like (*Client).Query, but allocates the value to unmarshal the response into.
+// T defines the query in the same way as the (pointed-to) type of the q argument of (*Client).Query, for example a struct type.
+// Like (*Client).Query, the operation constructed from T is cached by client.
+func Query[T any](ctx context.Context, client *Client, variables any) (T, *http.Response, error) {
+	var q T
+	resp, err := client.Query(ctx, &q, variables)
+	return q, resp, err
+}
+
+// Mutate is like Query, but does a mutation operation. See (*Client).Mutate.
+func Mutate[T any](ctx context.Context, client *Client, variables any) (T, *http.Response, error) {
+	var m T
+	resp, err := client.Mutate(ctx, &m, variables)
+	return m, resp, err
+}
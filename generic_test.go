@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Query(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"me":{"name":"hi"}}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport})
+		type MeQuery struct {
+			Me struct {
+				Name string
+			}
+		}
+		q, resp, err := Query[MeQuery](context.Background(), c, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Equal(t, "hi", q.Me.Name)
+		}
+	})
+}
+
+func Test_Mutate(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"errors":[{"message":"msg1"}]}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport})
+		type DeleteMutation struct {
+			Delete bool `graphql:"delete(id:$id)"`
+		}
+		_, _, err := Mutate[DeleteMutation](context.Background(), c, map[string]any{"id": ID{"1"}})
+		assert.ErrorContains(t, err, "200-response with errors: ")
+		if assert.IsType(t, &Error{}, err) {
+			assert.Equal(t, "mutation($id:ID!){delete(id:$id)}", err.(*Error).Operation)
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/graphql/ident"
+
+	"github.com/jbrekelmans/go-graphql/mapping"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// marshalerOf returns rv, or a pointer to rv (or to a copy of rv if rv is not addressable), if it implements json.Marshaler
+// or encoding.TextMarshaler. ok is false if neither rv nor pointers to rv implement these interfaces.
+func marshalerOf(rv reflect.Value) (_ reflect.Value, ok bool) {
+	t := rv.Type()
+	if t.Kind() == reflect.Interface {
+		return rv, false
+	}
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return rv, true
+	}
+	if pt := reflect.PointerTo(t); pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+		if rv.CanAddr() {
+			return rv.Addr(), true
+		}
+		prv := reflect.New(t)
+		prv.Elem().Set(rv)
+		return prv, true
+	}
+	return rv, false
+}
+
+// inputEncoder encodes variable values as JSON. Unlike "encoding/json", inputEncoder marshals values of Go types that map to
+// custom scalars as per a ScalarRegistry, and leaves out absent Omittable values.
+type inputEncoder struct {
+	b       bytes.Buffer
+	scalars *ScalarRegistry
+}
+
+// encodeVariables encodes the values of vars as a JSON object. Omitted variables are left out, see variable.omitted.
+func encodeVariables(vars []variable, scalars *ScalarRegistry) (json.RawMessage, error) {
+	e := inputEncoder{
+		scalars: scalars,
+	}
+	e.b.WriteByte('{')
+	n := 0
+	for _, v := range vars {
+		if v.omitted() {
+			continue
+		}
+		if n > 0 {
+			e.b.WriteByte(',')
+		}
+		n++
+		if err := e.marshalValue(v.name); err != nil {
+			return nil, err
+		}
+		e.b.WriteByte(':')
+		if err := e.encode(reflect.ValueOf(v.value)); err != nil {
+			return nil, fmt.Errorf(`error encoding variable $%s: %w`, v.name, err)
+		}
+	}
+	e.b.WriteByte('}')
+	return e.b.Bytes(), nil
+}
+
+func (e *inputEncoder) encode(rv reflect.Value) error {
+	if !rv.IsValid() {
+		e.b.WriteString("null")
+		return nil
+	}
+	t := rv.Type()
+	if _, ok := e.scalars.Lookup(t); ok {
+		b, err := e.scalars.marshal(rv)
+		if err != nil {
+			return err
+		}
+		e.b.Write(b)
+		return nil
+	}
+	if t.Implements(omittableType) && t.Kind() != reflect.Interface {
+		// Absent values that are not left out by the caller (e.g. elements of lists) are encoded as null.
+		v, _ := rv.Interface().(omittable).omittableValue()
+		return e.encode(reflect.ValueOf(v))
+	}
+	if t.Implements(varValueType) && t.Kind() != reflect.Interface {
+		return e.encode(reflect.ValueOf(rv.Interface().(varValue).value()))
+	}
+	if mrv, ok := marshalerOf(rv); ok {
+		return e.marshal(mrv)
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			e.b.WriteString("null")
+			return nil
+		}
+		return e.encode(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices and arrays are encoded like "encoding/json" does.
+			return e.marshal(rv)
+		}
+		if t.Kind() == reflect.Slice && rv.IsNil() {
+			e.b.WriteString("null")
+			return nil
+		}
+		e.b.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				e.b.WriteByte(',')
+			}
+			if err := e.encode(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.b.WriteByte(']')
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return e.marshal(rv)
+		}
+		if rv.IsNil() {
+			e.b.WriteString("null")
+			return nil
+		}
+		return e.encodeMap(rv)
+	case reflect.Struct:
+		return e.encodeStruct(rv)
+	default:
+		return e.marshal(rv)
+	}
+	return nil
+}
+
+// encodeMap encodes rv, which is a map with string keys, as a JSON object.
+func (e *inputEncoder) encodeMap(rv reflect.Value) error {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	e.b.WriteByte('{')
+	n := 0
+	for _, key := range keys {
+		value := rv.MapIndex(key)
+		if isAbsent(value) {
+			continue
+		}
+		if n > 0 {
+			e.b.WriteByte(',')
+		}
+		n++
+		if err := e.marshalValue(key.String()); err != nil {
+			return err
+		}
+		e.b.WriteByte(':')
+		if err := e.encode(value); err != nil {
+			return err
+		}
+	}
+	e.b.WriteByte('}')
+	return nil
+}
+
+// encodeStruct encodes rv, which is a struct that is an input object, as a JSON object. Fields are named like the fields of
+// output structs: the name defaults to the field name in lowerCamelCase and can be overridden with a graphql tag, e.g.
+// `graphql:"firstName"`. Fields with tag `graphql:"-"` are skipped, and the fields of embedded structs without tag are
+// promoted.
+// For compatibility with "encoding/json", the name and omitempty option of a json tag are honored if a field has no graphql
+// tag. Absent Omittable fields are left out. Unlike "encoding/json", the string option of json tags is ignored, and promoted
+// fields are not subject to the dominance rules of "encoding/json": if several fields have the same name, all of them are
+// encoded.
+func (e *inputEncoder) encodeStruct(rv reflect.Value) error {
+	e.b.WriteByte('{')
+	n := 0
+	err := inputObjectFields(rv, func(f reflect.StructField, name string, value reflect.Value) error {
+		if n > 0 {
+			e.b.WriteByte(',')
+		}
+		n++
+		if err := e.marshalValue(name); err != nil {
+			return err
+		}
+		e.b.WriteByte(':')
+		if err := e.encode(value); err != nil {
+			return fmt.Errorf(`field %s: %w`, f.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	e.b.WriteByte('}')
+	return nil
+}
+
+// inputObjectFields calls fn for each field of struct value rv, which is an input object, that is not left out. See
+// encodeStruct for how fields are named and left out.
+func inputObjectFields(rv reflect.Value, fn func(f reflect.StructField, name string, value reflect.Value) error) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omitEmpty, err := inputFieldName(f)
+		if err != nil {
+			return fmt.Errorf(`Go struct field %v.%s: %w`, t, f.Name, err)
+		}
+		if name == "-" {
+			continue
+		}
+		fieldValue := rv.Field(i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fieldValue.Kind() == reflect.Pointer {
+					if fieldValue.IsNil() {
+						continue
+					}
+					fieldValue = fieldValue.Elem()
+				}
+				if err := inputObjectFields(fieldValue, fn); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() || isAbsent(fieldValue) || (omitEmpty && isEmptyValue(fieldValue)) {
+			continue
+		}
+		if name == "" {
+			name = ident.ParseMixedCaps(f.Name).ToLowerCamelCase()
+		}
+		if err := fn(f, name, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputFieldName returns the name of field f of an input object as defined by its graphql or json tag, "-" if the field
+// is skipped, or an empty string if the field has no name defined by a tag.
+func inputFieldName(f reflect.StructField) (name string, omitEmpty bool, err error) {
+	if tag, ok := f.Tag.Lookup("graphql"); ok {
+		name = strings.TrimSpace(tag)
+		if name != "-" && !mapping.IsName(name) {
+			return "", false, fmt.Errorf(`invalid graphql tag %#v of input object field (want a name or "-")`, tag)
+		}
+		return name, false, nil
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "-", false, nil
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, hasTagOption(opts, "omitempty"), nil
+}
+
+func hasTagOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue returns true if rv is empty as per the omitempty option of "encoding/json".
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// marshal encodes rv with "encoding/json".
+func (e *inputEncoder) marshal(rv reflect.Value) error {
+	return e.marshalValue(rv.Interface())
+}
+
+func (e *inputEncoder) marshalValue(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.b.Write(b)
+	return nil
+}
@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_encodeVariables(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		s := "x"
+		actual, err := encodeVariables(mustNewVariables(t, map[string]any{
+			"b":    []*string{&s, nil},
+			"a":    ID{"1"},
+			"ids":  [][16]byte{{1}},
+			"m":    map[string]any{"z": 1, "y": nil},
+			"nil":  nil,
+			"time": Var[time.Time]{Type: "DateTime!", Value: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		}), defaultScalars)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"a":"1","b":["x",null],"ids":["01000000-0000-0000-0000-000000000000"],"m":{"y":null,"z":1},`+
+				`"nil":null,"time":"2020-01-02T03:04:05Z"}`, string(actual))
+		}
+	})
+	t.Run("Case2", func(t *testing.T) {
+		_, err := encodeVariables(mustNewVariables(t, map[string]any{"x": jsonMarshalBomb{}}), nil)
+		assert.ErrorContains(t, err, "jsonMarshalBomb: boom!")
+	})
+	t.Run("Case3", func(t *testing.T) {
+		actual, err := encodeVariables(mustNewVariables(t, struct {
+			Login string
+			First int `graphql:"first:Int"`
+		}{Login: "x", First: 2}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"login":"x","first":2}`, string(actual))
+		}
+	})
+	t.Run("Case4", func(t *testing.T) {
+		type embedded struct {
+			C int
+		}
+		actual, err := encodeVariables(mustNewVariables(t, map[string]any{
+			"input": &struct {
+				A string `json:"a"`
+				B string `json:"b,omitempty"`
+				S string `json:"-"`
+				embedded
+				unexported int
+			}{A: "x", embedded: embedded{C: 1}},
+		}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"input":{"a":"x","c":1}}`, string(actual))
+		}
+	})
+	t.Run("Case5", func(t *testing.T) {
+		type embedded struct {
+			ZipCode string
+		}
+		actual, err := encodeVariables(mustNewVariables(t, map[string]any{
+			"input": struct {
+				FirstName string
+				Surname   string `graphql:"lastName" json:"surname"`
+				Password  string `graphql:"-"`
+				Note      string `json:"note,omitempty"`
+				*embedded
+			}{FirstName: "x", Surname: "y", Password: "z", embedded: &embedded{ZipCode: "1234"}},
+		}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"input":{"firstName":"x","lastName":"y","zipCode":"1234"}}`, string(actual))
+		}
+	})
+	t.Run("Case6", func(t *testing.T) {
+		_, err := encodeVariables(mustNewVariables(t, map[string]any{
+			"input": struct {
+				A string `graphql:"a(x: 1)"`
+			}{},
+		}), nil)
+		assert.ErrorContains(t, err, `Go struct field struct { A string "graphql:\"a(x: 1)\"" }.A: invalid graphql tag `+
+			`"a(x: 1)" of input object field (want a name or "-")`)
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		two := 2
+		actual, err := encodeVariables(mustNewVariables(t, struct {
+			First  *int           `graphql:"first:Int=20"`
+			Second *int           `graphql:"second:Int=20"`
+			Limit  int            `graphql:"limit:Int=10"`
+			After  Omittable[int] `graphql:"after:Int=5"`
+			Before *int           `graphql:"before:Int"`
+			Last   Var[*int]
+			Offset Var[*int]
+			Skip   Var[Omittable[int]]
+		}{
+			Second: &two,
+			After:  OmittableNull[int](),
+			Last:   Var[*int]{Default: OmittableValue(&two)},
+			Offset: Var[*int]{Value: &two, Default: OmittableValue[*int](nil)},
+			Skip:   Var[Omittable[int]]{Value: OmittableValue(0), Default: OmittableValue(OmittableValue(1))},
+		}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"second":2,"limit":0,"after":null,"before":null,"offset":2,"skip":0}`, string(actual))
+		}
+	})
+	t.Run("TextMarshaler", func(t *testing.T) {
+		ip := netip.MustParseAddr("1.2.3.4")
+		actual, err := encodeVariables(mustNewVariables(t, map[string]any{
+			"ip":    ip,
+			"ips":   []netip.Addr{ip},
+			"color": testRGB{R: 255},
+			"input": &struct {
+				Color testRGB
+			}{Color: testRGB{G: 255}},
+		}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"color":"#ff0000","input":{"color":"#00ff00"},"ip":"1.2.3.4","ips":["1.2.3.4"]}`,
+				string(actual))
+		}
+	})
+}
+
+// testRGB implements encoding.TextMarshaler with a pointer receiver.
+type testRGB struct {
+	R, G, B uint8
+}
+
+func (c *testRGB) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)), nil
+}
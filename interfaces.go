@@ -0,0 +1,107 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+
+	internalJSON "github.com/jbrekelmans/go-graphql/json"
+	"github.com/jbrekelmans/go-graphql/mapping"
+)
+
+// InterfaceRegistry maps Go interface types to the Go types that implement them, by the names of the GraphQL object types
+// that the implementations correspond to. This way GraphQL interfaces and unions can be selected by fields of Go interface
+// types:
+//
+//	type SearchResult interface{ isSearchResult() }
+//
+//	r := graphql.NewInterfaceRegistry()
+//	graphql.RegisterImplementation[SearchResult, *User](r, "User")
+//	graphql.RegisterImplementation[SearchResult, *Repo](r, "Repo")
+//
+// The selection set of a field of a registered Go interface type (e.g. SearchResult) selects __typename and an inline
+// fragment for each implementation (e.g. "... on User{...}"), and the JSON object of the field is unmarshaled into a new
+// value of the implementation whose GraphQL object type is the __typename of the JSON object.
+//
+// An InterfaceRegistry must not be modified after it is passed to NewClient or another function of this package.
+type InterfaceRegistry struct {
+	interfaces map[reflect.Type][]implementation
+}
+
+// implementation is a Go type that implements a registered Go interface type.
+type implementation struct {
+	// typename is the name of the GraphQL object type, e.g. "User".
+	typename string
+	t        reflect.Type
+}
+
+// NewInterfaceRegistry creates an empty registry.
+func NewInterfaceRegistry() *InterfaceRegistry {
+	return &InterfaceRegistry{
+		interfaces: map[reflect.Type][]implementation{},
+	}
+}
+
+// Register registers Go type t as the implementation of Go interface type iface that corresponds to the GraphQL object type
+// named typename. t is typically a struct type or a pointer to a struct type. Implementations are selected in the order in
+// which they are registered. Registering a typename again replaces its implementation.
+// Register panics if iface is not an interface type, if t does not implement iface, or if typename is not a Name token.
+func (r *InterfaceRegistry) Register(iface reflect.Type, typename string, t reflect.Type) {
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Errorf(`graphql: cannot register implementation of non-interface type %v`, iface))
+	}
+	if !t.Implements(iface) {
+		panic(fmt.Errorf(`graphql: type %v does not implement interface type %v`, t, iface))
+	}
+	if !mapping.IsName(typename) {
+		panic(fmt.Errorf(`graphql: invalid typename %#v of implementation %v of interface type %v`, typename, t, iface))
+	}
+	impls := r.interfaces[iface]
+	for i := range impls {
+		if impls[i].typename == typename {
+			impls[i].t = t
+			return
+		}
+	}
+	r.interfaces[iface] = append(impls, implementation{
+		typename: typename,
+		t:        t,
+	})
+}
+
+// RegisterImplementation registers Go type T as an implementation of Go interface type I. See (*InterfaceRegistry).Register.
+func RegisterImplementation[I, T any](r *InterfaceRegistry, typename string) {
+	r.Register(reflect.TypeOf((*I)(nil)).Elem(), typename, reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// implementations returns the implementations of Go interface type t, in the order in which they are registered.
+func (r *InterfaceRegistry) implementations(t reflect.Type) []implementation {
+	if r == nil {
+		return nil
+	}
+	return r.interfaces[t]
+}
+
+// HasImplementations implements the json.InterfaceTypes interface.
+func (r *InterfaceRegistry) HasImplementations(t reflect.Type) bool {
+	return len(r.implementations(t)) > 0
+}
+
+// Implementation implements the json.InterfaceTypes interface.
+func (r *InterfaceRegistry) Implementation(t reflect.Type, typename string) (reflect.Type, bool) {
+	for _, impl := range r.implementations(t) {
+		if impl.typename == typename {
+			return impl.t, true
+		}
+	}
+	return nil, false
+}
+
+var _ internalJSON.InterfaceTypes = (*InterfaceRegistry)(nil)
+
+// WithInterfaces configures the implementations of Go interface types that select GraphQL interfaces and unions. See
+// InterfaceRegistry.
+func WithInterfaces(r *InterfaceRegistry) Option {
+	return func(o *options) {
+		o.interfaces = r
+	}
+}
@@ -0,0 +1,89 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSearchResult interface {
+	isSearchResult()
+}
+
+type testUser struct {
+	Login string
+}
+
+func (*testUser) isSearchResult() {}
+
+type testRepo struct {
+	Name  string
+	Stars int
+}
+
+func (testRepo) isSearchResult() {}
+
+func newTestInterfaceRegistry() *InterfaceRegistry {
+	r := NewInterfaceRegistry()
+	RegisterImplementation[testSearchResult, *testUser](r, "User")
+	RegisterImplementation[testSearchResult, testRepo](r, "Repo")
+	return r
+}
+
+func Test_InterfaceRegistry(t *testing.T) {
+	type Query struct {
+		Search []testSearchResult `graphql:"search(query: \"go\")"`
+	}
+	t.Run("ConstructQuery", func(t *testing.T) {
+		actual, err := ConstructQuery(&Query{}, nil, WithInterfaces(newTestInterfaceRegistry()))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query{search(query: "go"){__typename,... on User{login}... on Repo{name,stars}}}`, actual)
+		}
+	})
+	t.Run("Client", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"search":[{"__typename":"User","login":"henk"},` +
+				`{"__typename":"Repo","name":"go-graphql","stars":3}]}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport},
+			WithInterfaces(newTestInterfaceRegistry()))
+		var q Query
+		_, err := c.Query(context.Background(), &q, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []testSearchResult{&testUser{Login: "henk"}, testRepo{Name: "go-graphql", Stars: 3}}, q.Search)
+		}
+	})
+	t.Run("UnknownTypename", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"search":[{"__typename":"Issue","title":"bug"}]}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport},
+			WithInterfaces(newTestInterfaceRegistry()))
+		var q Query
+		_, err := c.Query(context.Background(), &q, nil)
+		assert.ErrorContains(t, err, `Go interface type graphql.testSearchResult has no implementation for __typename "Issue"`)
+	})
+	t.Run("Unregistered", func(t *testing.T) {
+		_, err := ConstructQuery(&Query{}, nil)
+		assert.EqualError(t, err, `error at Go struct field Search: unsupported Go type graphql.testSearchResult (register a `+
+			`custom scalar to select it as a leaf field)`)
+	})
+	t.Run("Register", func(t *testing.T) {
+		r := NewInterfaceRegistry()
+		iface := reflect.TypeOf((*testSearchResult)(nil)).Elem()
+		assert.PanicsWithError(t, `graphql: type graphql.testUser does not implement interface type `+
+			`graphql.testSearchResult`, func() {
+			r.Register(iface, "User", reflect.TypeOf(testUser{}))
+		})
+		assert.PanicsWithError(t, `graphql: cannot register implementation of non-interface type graphql.testRepo`, func() {
+			r.Register(reflect.TypeOf(testRepo{}), "Repo", reflect.TypeOf(testRepo{}))
+		})
+		assert.PanicsWithError(t, `graphql: invalid typename "" of implementation graphql.testRepo of interface type `+
+			`graphql.testSearchResult`, func() {
+			r.Register(iface, "", reflect.TypeOf(testRepo{}))
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package json
+
+import "reflect"
+
+// Option configures Unmarshal.
+type Option func(*options)
+
+type options struct {
+	scalars ScalarTypes
+	// possibleTypes maps names of abstract types to sets of names of object types.
+	possibleTypes map[string]map[string]struct{}
+	// objectTypes is the set of object types of possibleTypes.
+	objectTypes map[string]struct{}
+	interfaces  InterfaceTypes
+	// ignoreUnknownProperties is true if properties of JSON objects that are not mapped to fields are skipped.
+	ignoreUnknownProperties bool
+	// caseInsensitiveProperties is true if names of properties of JSON objects are matched case-insensitively.
+	caseInsensitiveProperties bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ScalarTypes defines which Go types represent custom GraphQL scalars, and how to unmarshal them.
+type ScalarTypes interface {
+	// IsScalar returns true if Go type t represents a custom GraphQL scalar.
+	IsScalar(t reflect.Type) bool
+
+	// UnmarshalScalar unmarshals JSON value b into v, which is a non-nil pointer to a Go value whose type represents a
+	// custom GraphQL scalar. b is never the JSON value null.
+	UnmarshalScalar(b []byte, v any) error
+}
+
+// WithScalarTypes configures Unmarshal to unmarshal JSON values into Go values whose types represent custom GraphQL
+// scalars (as per s) as a whole, even if the JSON values are objects or arrays.
+func WithScalarTypes(s ScalarTypes) Option {
+	return func(o *options) {
+		o.scalars = s
+	}
+}
+
+// InterfaceTypes defines which Go types implement Go interface types, by the __typename of the JSON objects that are
+// unmarshaled into them.
+type InterfaceTypes interface {
+	// HasImplementations returns true if Go interface type t has implementations.
+	HasImplementations(t reflect.Type) bool
+
+	// Implementation returns the Go type that implements Go interface type t, and that a JSON object whose __typename is
+	// typename is unmarshaled into.
+	Implementation(t reflect.Type, typename string) (reflect.Type, bool)
+}
+
+// WithInterfaceTypes configures Unmarshal to unmarshal JSON objects into Go values of interface types that have
+// implementations (as per i): a new value of the implementation that corresponds to the "__typename" property of the JSON
+// object is unmarshaled into, and then stored in the Go value of interface type.
+func WithInterfaceTypes(i InterfaceTypes) Option {
+	return func(o *options) {
+		o.interfaces = i
+	}
+}
+
+// WithPossibleTypes configures which object types implement each interface and are members of each union, as a map from
+// names of interfaces and unions to names of object types. Unmarshal uses these to determine which inline fragments and
+// fragment spreads of a selection set apply to a JSON object with a "__typename" property, see matchesTypeCondition. Fields
+// of inline fragments and fragment spreads that do not apply are not unmarshaled into.
+func WithPossibleTypes(possibleTypes map[string][]string) Option {
+	return func(o *options) {
+		o.possibleTypes = make(map[string]map[string]struct{}, len(possibleTypes))
+		o.objectTypes = map[string]struct{}{}
+		for abstractType, objectTypes := range possibleTypes {
+			set := make(map[string]struct{}, len(objectTypes))
+			for _, objectType := range objectTypes {
+				set[objectType] = struct{}{}
+				o.objectTypes[objectType] = struct{}{}
+			}
+			o.possibleTypes[abstractType] = set
+		}
+	}
+}
+
+// matchesTypeCondition returns true if an inline fragment or fragment spread with type condition typeCondition applies to a
+// JSON object whose __typename is typename. Fragments without type condition and fragments whose type condition is typename
+// always apply. Otherwise, if typeCondition is an interface or union of possibleTypes, then the fragment applies if its
+// possible types include typename, and if typeCondition is an object type of possibleTypes then the fragment does not
+// apply.
+// Remaining type conditions are unknown, and may be interfaces or unions. If exclusive is true, then another fragment of the
+// selection set has type condition typename, and unknown type conditions are assumed to be other object types that do not
+// apply. Otherwise, fragments with unknown type conditions apply.
+func (o *options) matchesTypeCondition(typename, typeCondition string, exclusive bool) bool {
+	if typeCondition == "" || typeCondition == typename {
+		return true
+	}
+	if set, ok := o.possibleTypes[typeCondition]; ok {
+		_, ok = set[typename]
+		return ok
+	}
+	if _, isObjectType := o.objectTypes[typeCondition]; isObjectType {
+		return false
+	}
+	return !exclusive
+}
+
+// WithIgnoreUnknownProperties configures Unmarshal to skip properties of JSON objects that no Go struct field is mapped to.
+// By default, Unmarshal returns an error that contains the JSON path of the first such property, because a GraphQL response
+// only contains the fields that are selected.
+// Ignoring unknown properties is useful for JSON that is not a response to a query constructed from the Go struct, for
+// example when unmarshaling the results of hand-written documents into partial structs, or when servers add properties.
+func WithIgnoreUnknownProperties() Option {
+	return func(o *options) {
+		o.ignoreUnknownProperties = true
+	}
+}
+
+// WithCaseInsensitiveProperties configures Unmarshal to match names of properties of JSON objects to the response keys of
+// Go struct fields case-insensitively, like "encoding/json".Unmarshal. By default, names are matched exactly, because the
+// graphql package controls the response keys of the fields it selects. A property is unmarshaled into all fields whose
+// response keys match.
+func WithCaseInsensitiveProperties() Option {
+	return func(o *options) {
+		o.caseInsensitiveProperties = true
+	}
+}
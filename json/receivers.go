@@ -1,6 +1,7 @@
 package json
 
 import (
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -21,92 +22,166 @@ func (r *receivers) append(rv reflect.Value) {
 	*r = append(*r, rv)
 }
 
-// add adds rv. If rv is a (pointer to) struct then the fields of the struct that define inline fragments are also added.
-// This process is recursive. See code for the exact criteria for when fields of structs are added.
+// add adds rv, or the Go value wrapped by rv if rv is a ValueWrapper.
 func (r *receivers) add(rv reflect.Value) {
-	t := unwrapPointerType(rv.Type())
+	r.append(unwrapValueWrapper(rv))
+}
+
+// inlineFragments returns the receivers of the properties of a JSON object that is unmarshaled into r. For each receiver
+// in r that is a (pointer to) struct, the fields of the struct that define inline fragments or fragment spreads are also
+// included.
+// This process is recursive. See code for the exact criteria for when fields of structs are included.
+// If typename is not empty then it is the __typename of the JSON object, and fields that define inline fragments or
+// fragment spreads whose type conditions do not match typename are left out (see WithPossibleTypes). This way fields of
+// inline fragments of other types are not written, and pointers to their structs are not allocated.
+func (r receivers) inlineFragments(typename string, o *options) receivers {
+	// If a type condition is typename, then the type conditions that are not known to match typename are other object
+	// types.
+	exclusive := false
+	for _, rv := range r {
+		if typename != "" && hasTypeCondition(rv.Type(), typename, nil) {
+			exclusive = true
+			break
+		}
+	}
+	var recvNext receivers
+	for _, rv := range r {
+		// The initial memory storing the ancestors is stack-allocated.
+		var ancestorsArray [3]reflect.Type
+		recvNext.addInlineFragments(rv, typename, exclusive, o, ancestorsArray[:0])
+	}
+	return recvNext
+}
+
+// addInlineFragments adds rv and, if rv is a (pointer to) struct, recursively the fields of the struct that define inline
+// fragments or fragment spreads. ancestors are the struct types of the receivers whose fields are being added, which are
+// tracked to avoid infinite recursion for self-referential types.
+// Fields of inline fragments may be ValueWrappers of (pointers to) structs. Like pointers, they are added as is and unwrapped
+// when a field of the wrapped struct receives a JSON value, so that a wrapper only records that it is present if the inline
+// fragment applied.
+func (r *receivers) addInlineFragments(rv reflect.Value, typename string, exclusive bool, o *options,
+	ancestors stack[reflect.Type]) {
+	t := mapping.UnwrapPointerType(wrappedTypeOf(rv.Type()))
 	if t.Kind() != reflect.Struct {
 		r.append(rv)
 		return
 	}
+	ancestors.push(t)
 	// rv should be added to r, which is done below so we can lazily initialize structs.
-
-	// Recursively walk through struct fields.
-	// We use a queue instead of recursive calls of add,
-	// so we can use the local variable "seen".
-
-	// Initialize queue with single element rv.
-	// The initial memory storing the queue elements is stack-allocated.
-	var queueArray [3]reflect.Value
-	queueArray[0] = rv
-	queue := stack[reflect.Value](queueArray[:1])
-
-	// Track types to avoid infinite recursion for self-referential types.
-	var seenArray [3]reflect.Type
-	seenArray[0] = t
-	seen := stack[reflect.Type](seenArray[:1])
-
-	for !queue.empty() {
-		rv := queue.pop()
-		rvAdded := false
-		structType := unwrapPointerType(rv.Type())
-		for i := 0; i < structType.NumField(); i++ {
-			structField := structType.Field(i)
-			if !structField.IsExported() {
-				continue
-			}
-			fieldInfo := mapping.NewFieldInfo(structField)
-			if !fieldInfo.Inline() && !fieldInfo.IsInlineFragment() {
-				continue
-			}
-			t := unwrapPointerType(structField.Type)
-			if t.Kind() != reflect.Struct || stackContains(seen, t) {
-				continue
-			}
-			if !rvAdded {
-				rv = elemIfPointer(rv)
-				r.append(rv)
-				rvAdded = true
-			}
-			seen.push(t)
-			queue.push(rv.Field(i))
+	rvAdded := false
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		fieldInfo := mapping.NewFieldInfo(structField)
+		if !fieldInfo.Inline() && !fieldInfo.IsInlineFragment() && !fieldInfo.IsFragmentSpread() {
+			continue
+		}
+		if typename != "" && !o.matchesTypeCondition(typename, typeConditionOf(structField, fieldInfo), exclusive) {
+			continue
+		}
+		fieldType := mapping.UnwrapPointerType(wrappedTypeOf(structField.Type))
+		if fieldType.Kind() != reflect.Struct || stackContains(ancestors, fieldType) {
+			continue
 		}
 		if !rvAdded {
+			rv = elemIfPointer(unwrapValueWrapper(rv))
 			r.append(rv)
+			rvAdded = true
+		}
+		r.addInlineFragments(rv.Field(i), typename, exclusive, o, ancestors)
+	}
+	if !rvAdded {
+		r.append(rv)
+	}
+}
+
+// typeConditionOf returns the type condition of the inline fragment or fragment spread defined by struct field f, or an
+// empty string if f defines neither or the inline fragment has no type condition.
+func typeConditionOf(f reflect.StructField, fieldInfo mapping.FieldInfo) string {
+	switch {
+	case fieldInfo.IsInlineFragment():
+		return fieldInfo.TypeCondition()
+	case fieldInfo.IsFragmentSpread():
+		_, typeCondition, _ := mapping.NamedFragmentOf(f.Type)
+		return typeCondition
+	}
+	return ""
+}
+
+// hasTypeCondition returns true if the selection set defined by Go type t has an inline fragment or fragment spread with
+// type condition typeCondition, including those nested in other inline fragments, fragment spreads and inlined embedded
+// structs. ancestors are the struct types being scanned, to avoid infinite recursion.
+func hasTypeCondition(t reflect.Type, typeCondition string, ancestors []reflect.Type) bool {
+	t = mapping.UnwrapPointerType(wrappedTypeOf(t))
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, a := range ancestors {
+		if a == t {
+			return false
+		}
+	}
+	ancestors = append(ancestors, t)
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		fieldInfo := mapping.NewFieldInfo(structField)
+		if !fieldInfo.Inline() && !fieldInfo.IsInlineFragment() && !fieldInfo.IsFragmentSpread() {
+			continue
+		}
+		if typeConditionOf(structField, fieldInfo) == typeCondition ||
+			hasTypeCondition(structField.Type, typeCondition, ancestors) {
+			return true
 		}
 	}
+	return false
 }
 
 func (r receivers) copy() receivers {
 	return append(receivers(nil), r...)
 }
 
-// mapArrayElement derives a set of receivers from r that should receive an element of a JSON array.
-func (r receivers) mapArrayElement() receivers {
+// mapArrayElement derives a set of receivers from r that should receive the element at index index of a JSON array.
+// Returns an error if a receiver is a Go array that is too short.
+func (r receivers) mapArrayElement(index int) (receivers, error) {
 	var recvNext receivers
 	for _, rv := range r {
-		// rv must be a slice type.
-		if rv.Kind() != reflect.Slice {
-			panic(fmt.Errorf(`receivers.mapArrayElement: r contains non-slice type %v`, rv.Type()))
+		switch rv.Kind() {
+		case reflect.Slice:
+			elemType := rv.Type().Elem()
+			zeroRV := reflect.Zero(elemType)
+			rv.Set(reflect.Append(rv, zeroRV))
+			recvNext.add(rv.Index(rv.Len() - 1))
+		case reflect.Array:
+			if index >= rv.Len() {
+				return nil, fmt.Errorf(`cannot unmarshal JSON array with more than %d elements into Go array type %v`,
+					rv.Len(), rv.Type())
+			}
+			recvNext.add(rv.Index(index))
+		default:
+			// rv must be a slice or array type.
+			panic(fmt.Errorf(`receivers.mapArrayElement: r contains non-slice and non-array type %v`, rv.Type()))
 		}
-		elemType := rv.Type().Elem()
-		zeroRV := reflect.Zero(elemType)
-		rv.Set(reflect.Append(rv, zeroRV))
-		rv := rv.Index(rv.Len() - 1)
-		recvNext.add(rv)
 	}
-	return recvNext
+	return recvNext, nil
 }
 
+// mapArrayStartInPlace prepares r to receive the elements of a JSON array. Slices are truncated, and arrays are zeroed so
+// that elements beyond the length of the JSON array are zero.
 func (r receivers) mapArrayStartInPlace() error {
-	// TODO support unmarshaling JSON arrays into Go arrays.
 	for i, rv := range r {
-		t := unwrapPointerType(rv.Type())
-		if t.Kind() != reflect.Slice {
-			return fmt.Errorf(`cannot unmarshal JSON array into non-slice type %v`, rv.Type())
+		t := mapping.UnwrapPointerType(rv.Type())
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return fmt.Errorf(`cannot unmarshal JSON array into non-slice and non-array type %v`, rv.Type())
 		}
 		rv := elemIfPointer(rv)
-		if n := rv.Len(); n > 0 {
+		if t.Kind() == reflect.Array {
+			rv.Set(reflect.Zero(t))
+		} else if n := rv.Len(); n > 0 {
 			rv.SetLen(0)
 		}
 		r[i] = rv
@@ -114,16 +189,14 @@ func (r receivers) mapArrayStartInPlace() error {
 	return nil
 }
 
-// mapPropertyName derives a set of receivers from r that should receive the value of a JSON object
-// property named propertyName.
-// For each receiver in r: if the receiver is a struct and has a field mapped to propertyName, then
-// field is added to the result.
-// Returns an error if the result would be empty (as this indicates a bug in the bigger picture: we selected a field in
-// GraphQL but there is no location to unmarshal).
-func (r receivers) mapPropertyName(propertyName string) (receivers, error) {
+// fields derives a set of receivers from r that should receive the value of a JSON object property named propertyName.
+// For each receiver in r: if the receiver is a struct and has a field mapped to propertyName, then the field is added to the
+// result. Names are matched exactly, unless o configures case-insensitive matching (see WithCaseInsensitiveProperties).
+func (r receivers) fields(propertyName string, o *options) receivers {
 	var recvNext receivers
 	for _, rv := range r {
-		t := unwrapPointerType(rv.Type())
+		// rv is a ValueWrapper if it is the field of an inline fragment (see addInlineFragments).
+		t := mapping.UnwrapPointerType(wrappedTypeOf(rv.Type()))
 		if t.Kind() != reflect.Struct {
 			continue
 		}
@@ -137,19 +210,14 @@ func (r receivers) mapPropertyName(propertyName string) (receivers, error) {
 			if fieldName == "" {
 				continue
 			}
-			if !strings.EqualFold(fieldName, propertyName) {
+			if fieldName != propertyName && !(o.caseInsensitiveProperties && strings.EqualFold(fieldName, propertyName)) {
 				continue
 			}
-			rv = elemIfPointer(rv)
+			rv = elemIfPointer(unwrapValueWrapper(rv))
 			recvNext.add(rv.Field(i))
 		}
 	}
-	if len(recvNext) == 0 {
-		// TODO add path of property to error msg
-		return nil, fmt.Errorf(`JSON object has property named %#v but no receiver struct has a field mapped to that property`,
-			propertyName)
-	}
-	return recvNext, nil
+	return recvNext
 }
 
 // same returns true if the first elements of r and other are stored at the same memory address.
@@ -158,6 +226,125 @@ func (r receivers) same(other receivers) bool {
 	return len(r) > 0 && len(other) > 0 && &r[0] == &other[0]
 }
 
+// hasScalar returns true if r contains a receiver whose type represents a custom scalar.
+func (r receivers) hasScalar(o *options) bool {
+	if o.scalars == nil {
+		return false
+	}
+	for _, rv := range r {
+		if o.scalars.IsScalar(mapping.UnwrapPointerType(rv.Type())) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInterface returns true if r contains a receiver whose type is an interface type with implementations.
+func (r receivers) hasInterface(o *options) bool {
+	if o.interfaces == nil {
+		return false
+	}
+	for _, rv := range r {
+		if t := mapping.UnwrapPointerType(rv.Type()); t.Kind() == reflect.Interface && o.interfaces.HasImplementations(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOpaque returns true if r contains a receiver whose type is opaque (see isOpaque).
+func (r receivers) hasOpaque() bool {
+	for _, rv := range r {
+		if isOpaque(mapping.UnwrapPointerType(rv.Type())) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// IsUnmarshaler returns true if t is not an interface or pointer type, and pointers to t implement json.Unmarshaler or
+// encoding.TextUnmarshaler. Unmarshal unmarshals JSON values into Go values of such types as a whole.
+func IsUnmarshaler(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Pointer {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+// isOpaque returns true if JSON values are unmarshaled into Go type t as a whole with "encoding/json".Unmarshal, instead of
+// being walked. This is the case if t is a map or interface type, or if IsUnmarshaler(t). Note that interface types with
+// implementations (see WithInterfaceTypes) are handled before opaque types.
+func isOpaque(t reflect.Type) bool {
+	return t.Kind() == reflect.Map || t.Kind() == reflect.Interface || IsUnmarshaler(t)
+}
+
+// unmarshalRaw unmarshals JSON value b into each receiver in r. Receivers whose types represent custom scalars receive b as
+// a whole, and receivers whose types are interface types with implementations receive new values of implementations (see
+// WithInterfaceTypes). Receivers whose types are opaque (see isOpaque) receive b as a whole via "encoding/json".Unmarshal.
+// path is the JSON path of b.
+func (r receivers) unmarshalRaw(b []byte, o *options, path string) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var others receivers
+	for _, rv := range r {
+		t := mapping.UnwrapPointerType(rv.Type())
+		switch {
+		case o.scalars != nil && o.scalars.IsScalar(t):
+			rv := elemIfPointer(rv)
+			if err := o.scalars.UnmarshalScalar(b, rv.Addr().Interface()); err != nil {
+				return err
+			}
+		case o.interfaces != nil && t.Kind() == reflect.Interface && o.interfaces.HasImplementations(t):
+			if err := unmarshalImplementation(b, elemIfPointer(rv), o, path); err != nil {
+				return err
+			}
+		case isOpaque(t):
+			rv := elemIfPointer(rv)
+			if err := json.Unmarshal(b, rv.Addr().Interface()); err != nil {
+				return err
+			}
+		default:
+			others.append(rv)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	return runRaw(b, others, o, path)
+}
+
+// unmarshalImplementation unmarshals JSON object b into a new value of the implementation of the interface type of rv that
+// corresponds to the __typename of b, and stores the new value in rv. path is the JSON path of b.
+func unmarshalImplementation(b []byte, rv reflect.Value, o *options, path string) error {
+	var object struct {
+		Typename *string `json:"__typename"`
+	}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return fmt.Errorf(`cannot unmarshal JSON value into Go interface type %v: %w`, rv.Type(), err)
+	}
+	if object.Typename == nil {
+		return fmt.Errorf(`cannot unmarshal JSON object without property %#v into Go interface type %v`,
+			typenamePropertyName, rv.Type())
+	}
+	implType, ok := o.interfaces.Implementation(rv.Type(), *object.Typename)
+	if !ok {
+		return fmt.Errorf(`Go interface type %v has no implementation for __typename %#v`, rv.Type(), *object.Typename)
+	}
+	impl := reflect.New(implType).Elem()
+	if err := runRaw(b, newReceivers(impl), o, path); err != nil {
+		return err
+	}
+	rv.Set(impl)
+	return nil
+}
+
 // unmarshal unmarshals JSON into each receiver in r.
 func (r receivers) unmarshalJSON(jsonBytes []byte) error {
 	if string(jsonBytes) == "null" {
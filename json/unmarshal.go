@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+
+	"github.com/jbrekelmans/go-graphql/mapping"
 )
 
 // Unmarshal recursively processes the JSON data `b` and unmarshals the JSON values into the appropriate
@@ -14,11 +16,14 @@ import (
 // Unmarshal, unlike "encoding/json".Unmarshal, handles Go structs that define inline fragments of
 // GraphQL queries (and other special behaviour defined by the graphql package).
 //
-// The json.Unmarshaler interface is _not_ respected when JSON objects and arrays are being unmarshaled.
-// That is, UnmarshalJSON will never be called with JSON that is an object or array.
+// JSON values are unmarshaled as a whole into Go values whose types implement json.Unmarshaler or
+// encoding.TextUnmarshaler, with "encoding/json".Unmarshal. That is, UnmarshalJSON is called with the complete JSON value,
+// even if it is an object or array. Go values of map and interface types (such as map[string]any and any), and of types that
+// represent custom GraphQL scalars (see WithScalarTypes) are also unmarshaled as a whole.
 //
-// Unmarshaling JSON arrays into Go arrays is not supported and attempting to do so returns an error.
-func Unmarshal(b []byte, v any) error {
+// JSON arrays are unmarshaled into Go slices and arrays. Elements of Go arrays beyond the length of a JSON array are set to
+// zero, and unmarshaling a JSON array that is longer than a Go array returns an error.
+func Unmarshal(b []byte, v any, opts ...Option) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Pointer {
 		return fmt.Errorf(`v has non-pointer type %T`, v)
@@ -26,13 +31,16 @@ func Unmarshal(b []byte, v any) error {
 	if rv.IsNil() {
 		return fmt.Errorf(`v is nil`)
 	}
-	if unwrapPointerType(rv.Type()).Kind() != reflect.Struct {
+	if mapping.UnwrapPointerType(rv.Type()).Kind() != reflect.Struct {
 		return fmt.Errorf(`v is not a pointer-to-struct type`)
 	}
 	jsonDec := json.NewDecoder(bytes.NewReader(b))
 	jsonDec.UseNumber()
 
-	u := unmarshaler{tokens: jsonDec}
+	u := unmarshaler{
+		tokens:  jsonDec,
+		options: newOptions(opts),
+	}
 	err := u.Run(rv)
 	if err != nil {
 		return err
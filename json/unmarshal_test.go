@@ -1,6 +1,9 @@
 package json
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -91,6 +94,301 @@ func Test_Unmarshal(t *testing.T) {
 			assert.Equal(t, "123", q.Animal.Age)
 		}
 	})
+	t.Run("Branches with __typename", func(t *testing.T) {
+		var q struct {
+			Pets []struct {
+				Typename string `graphql:"__typename"`
+				Pet      struct {
+					Name string
+				} `graphql:"... on Pet"`
+				Dog *struct {
+					Name  string
+					Barks bool
+				} `graphql:"... on Dog"`
+				Cat *struct {
+					Name string
+				} `graphql:"... on Cat"`
+			}
+		}
+		json := `{"pets":[{"__typename":"Dog","name":"Rex","barks":true},{"__typename":"Cat","name":"Tom"}]}`
+		err := Unmarshal([]byte(json), &q, WithPossibleTypes(map[string][]string{"Pet": {"Cat", "Dog"}}))
+		if assert.NoError(t, err) && assert.Len(t, q.Pets, 2) {
+			assert.Equal(t, "Dog", q.Pets[0].Typename)
+			assert.Equal(t, "Rex", q.Pets[0].Pet.Name)
+			if assert.NotNil(t, q.Pets[0].Dog) {
+				assert.Equal(t, "Rex", q.Pets[0].Dog.Name)
+				assert.True(t, q.Pets[0].Dog.Barks)
+			}
+			assert.Nil(t, q.Pets[0].Cat)
+			assert.Equal(t, "Cat", q.Pets[1].Typename)
+			assert.Equal(t, "Tom", q.Pets[1].Pet.Name)
+			assert.Nil(t, q.Pets[1].Dog)
+			if assert.NotNil(t, q.Pets[1].Cat) {
+				assert.Equal(t, "Tom", q.Pets[1].Cat.Name)
+			}
+		}
+	})
+	t.Run("Branches with __typename without possible types", func(t *testing.T) {
+		var q struct {
+			Person struct {
+				Age string
+			} `graphql:"... on Person"`
+			Animal struct {
+				Age string
+			} `graphql:"... on Animal"`
+			Being struct {
+				Age string
+			} `graphql:"... on Being"`
+		}
+		json := `{"__typename":"Animal","age":"123"}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) {
+			// Animal matches exactly, so the other type conditions are assumed to be other object types.
+			assert.Equal(t, "", q.Person.Age)
+			assert.Equal(t, "123", q.Animal.Age)
+			assert.Equal(t, "", q.Being.Age)
+		}
+		q.Person.Age, q.Animal.Age, q.Being.Age = "", "", ""
+		err = Unmarshal([]byte(json), &q, WithPossibleTypes(map[string][]string{"Being": {"Animal"}}))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "", q.Person.Age)
+			assert.Equal(t, "123", q.Animal.Age)
+			assert.Equal(t, "123", q.Being.Age)
+		}
+		q.Person.Age, q.Animal.Age, q.Being.Age = "", "", ""
+		err = Unmarshal([]byte(`{"__typename":"Dog","age":"4"}`), &q, WithPossibleTypes(map[string][]string{
+			"Being": {"Animal"},
+		}))
+		if assert.NoError(t, err) {
+			// Person is unknown and no type condition matches exactly, so Person may be an interface or union.
+			assert.Equal(t, "4", q.Person.Age)
+			assert.Equal(t, "", q.Animal.Age)
+			assert.Equal(t, "", q.Being.Age)
+		}
+	})
+	t.Run("Fragment spreads with __typename", func(t *testing.T) {
+		var q struct {
+			Person *testPersonFields `graphql:"...PersonFields"`
+			Animal *testAnimalFields `graphql:"...AnimalFields"`
+		}
+		err := Unmarshal([]byte(`{"__typename":"Animal","age":"7"}`), &q)
+		if assert.NoError(t, err) {
+			assert.Nil(t, q.Person)
+			if assert.NotNil(t, q.Animal) {
+				assert.Equal(t, "7", q.Animal.Age)
+			}
+		}
+	})
+	t.Run("Fragment spreads", func(t *testing.T) {
+		type UserFields struct {
+			Name string
+		}
+		type BioFields struct {
+			Bio string
+		}
+		var q struct {
+			Viewer struct {
+				ID         string
+				UserFields `graphql:"...UserFields"`
+				Bio        *BioFields `graphql:"...BioFields"`
+			}
+		}
+		json := `{"viewer":{"id":"1","name":"Henk","bio":"Hi"}}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1", q.Viewer.ID)
+			assert.Equal(t, "Henk", q.Viewer.Name)
+			if assert.NotNil(t, q.Viewer.Bio) {
+				assert.Equal(t, "Hi", q.Viewer.Bio.Bio)
+			}
+		}
+	})
+	t.Run("Value wrappers", func(t *testing.T) {
+		var q struct {
+			Name  testWrapper
+			Email testWrapper
+		}
+		json := `{"name":"Henk"}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) {
+			assert.Equal(t, testWrapper{Value: "Henk", Present: true}, q.Name)
+			assert.Equal(t, testWrapper{}, q.Email)
+		}
+	})
+	t.Run("Scalar types", func(t *testing.T) {
+		var q struct {
+			Items []struct {
+				Point *testPoint
+				Name  string
+			}
+		}
+		json := `{"items":[{"point":{"x":1,"y":2},"name":"a"},{"point":null,"name":"b"}]}`
+		err := Unmarshal([]byte(json), &q, WithScalarTypes(testScalarTypes{}))
+		if assert.NoError(t, err) && assert.Len(t, q.Items, 2) {
+			assert.Equal(t, &testPoint{X: 1, Y: 2}, q.Items[0].Point)
+			assert.Equal(t, "a", q.Items[0].Name)
+			assert.Nil(t, q.Items[1].Point)
+			assert.Equal(t, "b", q.Items[1].Name)
+		}
+	})
+	t.Run("Arrays", func(t *testing.T) {
+		var q struct {
+			Points []struct {
+				Coordinates [2]float64
+			}
+			Tags [3]string
+		}
+		q.Tags = [3]string{"x", "y", "z"}
+		json := `{"points":[{"coordinates":[1,2]},{"coordinates":[3,4]}],"tags":["a","b"]}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) && assert.Len(t, q.Points, 2) {
+			assert.Equal(t, [2]float64{1, 2}, q.Points[0].Coordinates)
+			assert.Equal(t, [2]float64{3, 4}, q.Points[1].Coordinates)
+			assert.Equal(t, [3]string{"a", "b", ""}, q.Tags)
+		}
+	})
+	t.Run("JSON array longer than Go array", func(t *testing.T) {
+		var q struct {
+			Coordinates [2]float64
+		}
+		json := `{"coordinates":[1,2,3]}`
+		err := Unmarshal([]byte(json), &q)
+		assert.ErrorContains(t, err, `cannot unmarshal JSON array with more than 2 elements into Go array type [2]float64`)
+	})
+	t.Run("Interface types", func(t *testing.T) {
+		var q struct {
+			Pets []testPet
+			Best testPet
+		}
+		json := `{"pets":[{"name":"Rex","__typename":"Dog"},{"__typename":"Cat","lives":9}],"best":null}`
+		err := Unmarshal([]byte(json), &q, WithInterfaceTypes(testInterfaceTypes{}))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []testPet{&testDog{Name: "Rex"}, testCat{Lives: 9}}, q.Pets)
+			assert.Nil(t, q.Best)
+		}
+	})
+	t.Run("Interface types without __typename", func(t *testing.T) {
+		var q struct {
+			Best testPet
+		}
+		json := `{"best":{"name":"Rex"}}`
+		err := Unmarshal([]byte(json), &q, WithInterfaceTypes(testInterfaceTypes{}))
+		assert.EqualError(t, err, `cannot unmarshal JSON object without property "__typename" into Go interface type `+
+			`json.testPet`)
+	})
+	t.Run("Unmarshalers", func(t *testing.T) {
+		var q struct {
+			Geometry  testRawJSON
+			Bounds    *testRawJSON
+			Tags      []testRawJSON
+			Color     testColor
+			Colors    []testColor
+			Nullable  *testRawJSON
+			Unchanged testRawJSON
+		}
+		q.Unchanged = testRawJSON(`1`)
+		json := `{"geometry":{"type":"Point","coordinates":[1,2]},"bounds":[[0,0],[1,1]],"tags":[{"a":1},"b"],` +
+			`"color":"#ff0000","colors":["#00ff00"],"nullable":null,"unchanged":null}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) {
+			assert.Equal(t, testRawJSON(`{"type":"Point","coordinates":[1,2]}`), q.Geometry)
+			if assert.NotNil(t, q.Bounds) {
+				assert.Equal(t, testRawJSON(`[[0,0],[1,1]]`), *q.Bounds)
+			}
+			assert.Equal(t, []testRawJSON{testRawJSON(`{"a":1}`), testRawJSON(`"b"`)}, q.Tags)
+			assert.Equal(t, testColor{R: 0xff}, q.Color)
+			assert.Equal(t, []testColor{{G: 0xff}}, q.Colors)
+			assert.Nil(t, q.Nullable)
+			assert.Equal(t, testRawJSON(`1`), q.Unchanged)
+		}
+	})
+	t.Run("Maps and interfaces", func(t *testing.T) {
+		var q struct {
+			Counts map[string]int
+			Extra  any
+			Items  []any
+		}
+		json := `{"counts":{"a":1,"b":2},"extra":{"x":[1,"y"]},"items":[{"z":null},2]}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]int{"a": 1, "b": 2}, q.Counts)
+			assert.Equal(t, map[string]any{"x": []any{float64(1), "y"}}, q.Extra)
+			assert.Equal(t, []any{map[string]any{"z": nil}, float64(2)}, q.Items)
+		}
+	})
+	t.Run("TextUnmarshaler with JSON object", func(t *testing.T) {
+		var q struct {
+			Color testColor
+		}
+		json := `{"color":{"r":255}}`
+		err := Unmarshal([]byte(json), &q)
+		assert.ErrorContains(t, err, `cannot unmarshal object into Go value of type json.testColor`)
+	})
+	t.Run("Unknown properties", func(t *testing.T) {
+		var q struct {
+			Viewer struct {
+				Friends []struct {
+					Name string
+				}
+			}
+		}
+		json := `{"viewer":{"friends":[{"name":"a"},{"name":"b","extra":{"x":[1,{"y":2}]}}]}}`
+		err := Unmarshal([]byte(json), &q)
+		assert.EqualError(t, err, `JSON object has property named "extra" (at viewer.friends[1].extra) but no receiver `+
+			`struct has a field mapped to that property`)
+	})
+	t.Run("Unknown properties in implementations", func(t *testing.T) {
+		var q struct {
+			Pets [][]testPet
+		}
+		json := `{"pets":[[{"__typename":"Cat","lives":9}],[{"__typename":"Dog","name":"Rex","age":3}]]}`
+		err := Unmarshal([]byte(json), &q, WithInterfaceTypes(testInterfaceTypes{}))
+		assert.EqualError(t, err, `JSON object has property named "age" (at pets[1][0].age) but no receiver struct has a `+
+			`field mapped to that property`)
+	})
+	t.Run("Ignore unknown properties", func(t *testing.T) {
+		var q struct {
+			Viewer struct {
+				Friends []struct {
+					Name string
+				}
+			}
+		}
+		json := `{"extensions":{"cost":[1,{"a":[]}]},"viewer":{"friends":[{"id":1,"name":"a","tags":["x"]},` +
+			`{"extra":null,"name":"b"}]},"version":"2"}`
+		err := Unmarshal([]byte(json), &q, WithIgnoreUnknownProperties())
+		if assert.NoError(t, err) && assert.Len(t, q.Viewer.Friends, 2) {
+			assert.Equal(t, "a", q.Viewer.Friends[0].Name)
+			assert.Equal(t, "b", q.Viewer.Friends[1].Name)
+		}
+	})
+	t.Run("Case-sensitive properties", func(t *testing.T) {
+		var q struct {
+			URL  string `graphql:"URL"`
+			Link string `graphql:"url"`
+		}
+		json := `{"URL":"a","url":"b"}`
+		err := Unmarshal([]byte(json), &q)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "a", q.URL)
+			assert.Equal(t, "b", q.Link)
+		}
+		err = Unmarshal([]byte(`{"Url":"c"}`), &q)
+		assert.EqualError(t, err, `JSON object has property named "Url" (at Url) but no receiver struct has a field mapped `+
+			`to that property`)
+	})
+	t.Run("Case-insensitive properties", func(t *testing.T) {
+		var q struct {
+			UserID string
+			Name   string
+		}
+		json := `{"userId":"1","NAME":"a"}`
+		err := Unmarshal([]byte(json), &q, WithCaseInsensitiveProperties())
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1", q.UserID)
+			assert.Equal(t, "a", q.Name)
+		}
+	})
 	t.Run("JSON array into non-slice Go", func(t *testing.T) {
 		var q struct {
 			Person struct {
@@ -99,6 +397,103 @@ func Test_Unmarshal(t *testing.T) {
 		}
 		json := `{"person":[]}`
 		err := Unmarshal([]byte(json), &q)
-		assert.ErrorContains(t, err, `cannot unmarshal JSON array into non-slice type`)
+		assert.ErrorContains(t, err, `cannot unmarshal JSON array into non-slice and non-array type`)
 	})
 }
+
+type testWrapper struct {
+	Value   string
+	Present bool
+}
+
+var _ ValueWrapper = (*testWrapper)(nil)
+
+func (w *testWrapper) WrappedValue() any {
+	w.Present = true
+	return &w.Value
+}
+
+type testPoint struct {
+	X, Y int
+	// unexported prevents "encoding/json" from unmarshaling objects with unknown properties.
+	unexported bool
+}
+
+type testScalarTypes struct{}
+
+var _ ScalarTypes = testScalarTypes{}
+
+func (testScalarTypes) IsScalar(t reflect.Type) bool {
+	return t == reflect.TypeOf(testPoint{})
+}
+
+func (testScalarTypes) UnmarshalScalar(b []byte, v any) error {
+	return json.Unmarshal(b, v)
+}
+
+type testPersonFields struct {
+	Age string
+}
+
+func (testPersonFields) GraphQLFragment() (name, typeCondition string) {
+	return "PersonFields", "Person"
+}
+
+type testAnimalFields struct {
+	Age string
+}
+
+func (testAnimalFields) GraphQLFragment() (name, typeCondition string) {
+	return "AnimalFields", "Animal"
+}
+
+type testPet interface {
+	isPet()
+}
+
+type testDog struct {
+	Name string
+}
+
+func (*testDog) isPet() {}
+
+type testCat struct {
+	Lives int
+}
+
+func (testCat) isPet() {}
+
+type testInterfaceTypes struct{}
+
+var _ InterfaceTypes = testInterfaceTypes{}
+
+func (testInterfaceTypes) HasImplementations(t reflect.Type) bool {
+	return t == reflect.TypeOf((*testPet)(nil)).Elem()
+}
+
+func (testInterfaceTypes) Implementation(t reflect.Type, typename string) (reflect.Type, bool) {
+	switch typename {
+	case "Dog":
+		return reflect.TypeOf(&testDog{}), true
+	case "Cat":
+		return reflect.TypeOf(testCat{}), true
+	}
+	return nil, false
+}
+
+// testRawJSON records the JSON value it is unmarshaled from.
+type testRawJSON string
+
+func (r *testRawJSON) UnmarshalJSON(b []byte) error {
+	*r = testRawJSON(b)
+	return nil
+}
+
+type testColor struct {
+	R, G, B uint8
+}
+
+func (c *testColor) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return err
+}
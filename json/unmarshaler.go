@@ -1,10 +1,12 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
+	"strings"
 )
 
 type stateItem struct {
@@ -12,16 +14,26 @@ type stateItem struct {
 	// false if it is an array.
 	inObject bool
 	recv     receivers
+	// roots are the receivers of the JSON object if inObject is true, excluding the fields that define inline fragments
+	// and fragment spreads (see receivers.inlineFragments).
+	roots receivers
+	// index is the index of the next element if the inner-most value being decoded is an array.
+	index int
+	// property is the name of the property being decoded if the inner-most value being decoded is an object.
+	property string
 }
 
 type unmarshaler struct {
-	tokens *json.Decoder
-	state  stack[stateItem]
+	tokens  *json.Decoder
+	state   stack[stateItem]
+	options *options
+	// pathPrefix is the JSON path of the JSON value being decoded, if it is part of a larger JSON value.
+	pathPrefix string
 }
 
 // Run recursively walks through JSON values and unmarshals them into the appropriate values.
-// The json.Unmarshaler interface is _not_ respected when JSON objects and arrays are being decoded.
-// That is, UnmarshalJSON will never be called with JSON that is an object or array.
+// JSON values are decoded as a whole if the Go types they are unmarshaled into represent custom scalars (see
+// WithScalarTypes), are map or interface types, or implement json.Unmarshaler or encoding.TextUnmarshaler.
 func (u *unmarshaler) Run(rv reflect.Value) error {
 	token, err := u.tokens.Token()
 	if err != nil {
@@ -30,68 +42,217 @@ func (u *unmarshaler) Run(rv reflect.Value) error {
 	if token != json.Delim('{') {
 		return fmt.Errorf(`JSON value must be an object`)
 	}
-	u.state = stack[stateItem]{
-		stateItem{
-			inObject: true,
-			recv:     newReceivers(rv),
-		},
-	}
+	u.state = stack[stateItem]{u.objectStart(newReceivers(rv))}
+	return u.loop()
+}
+
+// loop processes tokens until the inner-most array or object being decoded (if any) ends.
+func (u *unmarshaler) loop() error {
 	for len(u.state) > 0 {
-		token, err = u.tokens.Token()
-		if err != nil {
-			return eofToUnexpected(err)
-		}
 		s := u.state.top()
 		recv := s.recv
 		if s.inObject {
-			if token != json.Delim('}') {
-				// Token is name of property of object.
-				propertyName := token.(string)
-				recv, err = recv.mapPropertyName(propertyName)
-				if err != nil {
+			token, err := u.tokens.Token()
+			if err != nil {
+				return eofToUnexpected(err)
+			}
+			if token == json.Delim('}') {
+				u.state.pop()
+				// TODO consider recycling receivers
+				continue
+			}
+			// Token is name of property of object.
+			propertyName := token.(string)
+			u.state[len(u.state)-1].property = propertyName
+			if propertyName == typenamePropertyName {
+				if err := u.typename(); err != nil {
 					return err
 				}
-				token, err = u.tokens.Token()
-				if err != nil {
-					return eofToUnexpected(err)
+				continue
+			}
+			recv = recv.fields(propertyName, u.options)
+			if len(recv) == 0 {
+				if u.options.ignoreUnknownProperties {
+					if err := u.skipValue(); err != nil {
+						return err
+					}
+					continue
 				}
+				// This indicates a bug in the bigger picture: we selected a field in GraphQL but there is no location to
+				// unmarshal.
+				return fmt.Errorf(`JSON object has property named %#v (at %s) but no receiver struct has a field mapped to `+
+					`that property`, propertyName, u.path())
 			}
-		} else if token != json.Delim(']') {
+		} else {
 			// In JSON array.
-			recv = recv.mapArrayElement()
-		}
-		switch {
-		case token == json.Delim('{'):
-			u.state.push(stateItem{
-				inObject: true,
-				recv:     recv,
-			})
-		case token == json.Delim('}'):
-			u.state.pop()
-			// TODO consider recycling receivers
-		case token == json.Delim('['):
-			recvNext := recv
-			if u.state.top().recv.same(recv) {
-				recvNext = recv.copy()
-			}
-			if err := recvNext.mapArrayStartInPlace(); err != nil {
-				return err
+			if !u.tokens.More() {
+				if _, err := u.tokens.Token(); err != nil {
+					return eofToUnexpected(err)
+				}
+				u.state.pop()
+				// TODO consider recycling receivers
+				continue
 			}
-			u.state.push(stateItem{
-				recv: recv,
-			})
-		case token == json.Delim(']'):
-			u.state.pop()
-			// TODO consider recycling receivers
-		default:
-			if err := recv.unmarshalAny(token); err != nil {
+			var err error
+			if recv, err = recv.mapArrayElement(s.index); err != nil {
 				return err
 			}
+			u.state[len(u.state)-1].index++
+		}
+		if err := u.value(recv); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// value starts processing the next JSON value. If the JSON value is an object or array then value pushes an item on
+// u.state, and the rest of the JSON value is processed by u.loop.
+func (u *unmarshaler) value(recv receivers) error {
+	if recv.hasScalar(u.options) || recv.hasInterface(u.options) || recv.hasOpaque() {
+		// Capture the JSON value as a whole.
+		var raw json.RawMessage
+		if err := u.tokens.Decode(&raw); err != nil {
+			return eofToUnexpected(err)
+		}
+		return recv.unmarshalRaw(raw, u.options, u.path())
+	}
+	token, err := u.tokens.Token()
+	if err != nil {
+		return eofToUnexpected(err)
+	}
+	switch {
+	case token == json.Delim('{'):
+		u.state.push(u.objectStart(recv))
+	case token == json.Delim('['):
+		if len(u.state) > 0 && u.state.top().recv.same(recv) {
+			recv = recv.copy()
+		}
+		if err := recv.mapArrayStartInPlace(); err != nil {
+			return err
+		}
+		u.state.push(stateItem{
+			recv: recv,
+		})
+	default:
+		if err := recv.unmarshalAny(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectStart returns the state item of a JSON object that is unmarshaled into recv.
+func (u *unmarshaler) objectStart(recv receivers) stateItem {
+	return stateItem{
+		inObject: true,
+		recv:     recv.inlineFragments("", u.options),
+		roots:    recv,
+	}
+}
+
+const typenamePropertyName = "__typename"
+
+// typename processes the value of the __typename property of the inner-most JSON object being decoded. Subsequent
+// properties of the JSON object are only unmarshaled into the inline fragments whose type conditions match the __typename.
+// Properties that precede the __typename property are unmarshaled into all inline fragments, which is why the graphql
+// package selects __typename first.
+func (u *unmarshaler) typename() error {
+	token, err := u.tokens.Token()
+	if err != nil {
+		return eofToUnexpected(err)
+	}
+	typename, ok := token.(string)
+	if !ok {
+		return fmt.Errorf(`JSON object has property %#v whose value is not a string`, typenamePropertyName)
+	}
+	s := &u.state[len(u.state)-1]
+	s.recv = s.roots.inlineFragments(typename, u.options)
+	// Unlike other properties, __typename does not need to be mapped to a field.
+	return s.recv.fields(typenamePropertyName, u.options).unmarshalAny(typename)
+}
+
+// skipValue skips the next JSON value, including all values nested in it.
+func (u *unmarshaler) skipValue() error {
+	depth := 0
+	for {
+		token, err := u.tokens.Token()
+		if err != nil {
+			return eofToUnexpected(err)
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// path returns the JSON path of the JSON value being decoded, e.g. "viewer.friends[2].name".
+func (u *unmarshaler) path() string {
+	var b strings.Builder
+	b.WriteString(u.pathPrefix)
+	for _, s := range u.state {
+		if !s.inObject {
+			// The index of the element being decoded precedes s.index.
+			fmt.Fprintf(&b, "[%d]", s.index-1)
+		} else if s.property != "" {
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(s.property)
+		}
+	}
+	return b.String()
+}
+
+// runRaw unmarshals JSON value b into each receiver in recv. path is the JSON path of b.
+func runRaw(b []byte, recv receivers, o *options, path string) error {
+	jsonDec := json.NewDecoder(bytes.NewReader(b))
+	jsonDec.UseNumber()
+	u := unmarshaler{
+		tokens:     jsonDec,
+		options:    o,
+		pathPrefix: path,
+	}
+	if err := u.value(recv); err != nil {
+		return err
+	}
+	return u.loop()
+}
+
+// ValueWrapper is implemented by pointers to Go types that wrap the Go value that a JSON value is unmarshaled into, and that
+// record whether the JSON value is present. For example, a wrapper can distinguish a property that is absent from a JSON
+// object from a property whose value is null.
+type ValueWrapper interface {
+	// WrappedValue records that the JSON value is present and returns a non-nil pointer to the wrapped Go value.
+	WrappedValue() any
+}
+
+var valueWrapperType = reflect.TypeOf((*ValueWrapper)(nil)).Elem()
+
+// unwrapValueWrapper returns the wrapped value if rv is an addressable ValueWrapper, and returns rv otherwise.
+func unwrapValueWrapper(rv reflect.Value) reflect.Value {
+	for rv.CanAddr() && rv.Addr().Type().Implements(valueWrapperType) {
+		rv = reflect.ValueOf(rv.Addr().Interface().(ValueWrapper).WrappedValue()).Elem()
+	}
+	return rv
+}
+
+// wrappedTypeOf returns the type of the Go value wrapped by values of type t if pointers to t implement ValueWrapper, and
+// returns t otherwise. The wrapped type is determined by unwrapping a new value, so that receivers can be inspected without
+// recording that a JSON value is present.
+func wrappedTypeOf(t reflect.Type) reflect.Type {
+	for reflect.PointerTo(t).Implements(valueWrapperType) {
+		t = reflect.TypeOf(reflect.New(t).Interface().(ValueWrapper).WrappedValue()).Elem()
+	}
+	return t
+}
+
 func elemIfPointer(rv reflect.Value) reflect.Value {
 	for rv.Kind() == reflect.Pointer {
 		if rv.IsNil() {
@@ -108,10 +269,3 @@ func eofToUnexpected(err error) error {
 	}
 	return err
 }
-
-func unwrapPointerType(t reflect.Type) reflect.Type {
-	for t.Kind() == reflect.Pointer {
-		t = t.Elem()
-	}
-	return t
-}
@@ -0,0 +1,320 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/jbrekelmans/go-graphql/mapping"
+)
+
+// Enum is implemented by Go types whose values are GraphQL enum values, e.g. "JEDI". Enum values are printed unquoted in
+// GraphQL literals (e.g. default values of variables), but are still encoded as JSON strings when used as variable values.
+type Enum interface {
+	// GraphQLEnum returns the enum value, which must be a Name other than "true", "false" and "null".
+	GraphQLEnum() string
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// literalPrinter prints Go values as GraphQL value literals (without variables).
+// See https://spec.graphql.org/October2021/#Value.
+//
+// Input objects are printed with the same field names as inputEncoder, values of Go types that map to custom scalars and
+// values of types that implement json.Marshaler or encoding.TextMarshaler are converted from their JSON encoding, and values
+// of types that implement Enum are printed as enum values.
+type literalPrinter struct {
+	b       bytes.Buffer
+	scalars *ScalarRegistry
+}
+
+func (p *literalPrinter) print(rv reflect.Value) error {
+	if !rv.IsValid() {
+		p.b.WriteString("null")
+		return nil
+	}
+	t := rv.Type()
+	if t.Implements(enumType) && t.Kind() != reflect.Interface && !(t.Kind() == reflect.Pointer && rv.IsNil()) {
+		v := rv.Interface().(Enum).GraphQLEnum()
+		if !mapping.IsName(v) || v == "true" || v == "false" || v == "null" {
+			return fmt.Errorf(`invalid enum value %#v of Go type %v`, v, t)
+		}
+		p.b.WriteString(v)
+		return nil
+	}
+	if _, ok := p.scalars.Lookup(t); ok {
+		b, err := p.scalars.marshal(rv)
+		if err != nil {
+			return err
+		}
+		return p.printJSON(b)
+	}
+	if t.Implements(omittableType) && t.Kind() != reflect.Interface {
+		v, _ := rv.Interface().(omittable).omittableValue()
+		return p.print(reflect.ValueOf(v))
+	}
+	if t.Implements(varValueType) && t.Kind() != reflect.Interface {
+		return p.print(reflect.ValueOf(rv.Interface().(varValue).value()))
+	}
+	if mrv, ok := marshalerOf(rv); ok {
+		return p.marshal(mrv)
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			p.b.WriteString("null")
+			return nil
+		}
+		return p.print(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices and arrays are printed like inputEncoder encodes them.
+			return p.marshal(rv)
+		}
+		if t.Kind() == reflect.Slice && rv.IsNil() {
+			p.b.WriteString("null")
+			return nil
+		}
+		p.b.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				p.b.WriteByte(',')
+			}
+			if err := p.print(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		p.b.WriteByte(']')
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return p.marshal(rv)
+		}
+		if rv.IsNil() {
+			p.b.WriteString("null")
+			return nil
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		p.b.WriteByte('{')
+		n := 0
+		for _, key := range keys {
+			value := rv.MapIndex(key)
+			if isAbsent(value) {
+				continue
+			}
+			if err := p.objectField(n, key.String()); err != nil {
+				return err
+			}
+			n++
+			if err := p.print(value); err != nil {
+				return err
+			}
+		}
+		p.b.WriteByte('}')
+	case reflect.Struct:
+		p.b.WriteByte('{')
+		n := 0
+		err := inputObjectFields(rv, func(f reflect.StructField, name string, value reflect.Value) error {
+			if err := p.objectField(n, name); err != nil {
+				return err
+			}
+			n++
+			if err := p.print(value); err != nil {
+				return fmt.Errorf(`field %s: %w`, f.Name, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		p.b.WriteByte('}')
+	case reflect.String:
+		p.string(rv.String())
+	default:
+		// Booleans and numbers are printed like their JSON encoding, which is also valid GraphQL.
+		return p.marshal(rv)
+	}
+	return nil
+}
+
+// objectField prints the name of the n-th field of an object.
+func (p *literalPrinter) objectField(n int, name string) error {
+	if !mapping.IsName(name) {
+		return fmt.Errorf(`invalid input object field name %#v`, name)
+	}
+	if n > 0 {
+		p.b.WriteByte(',')
+	}
+	p.b.WriteString(name)
+	p.b.WriteByte(':')
+	return nil
+}
+
+// string prints s as a StringValue literal. See https://spec.graphql.org/October2021/#StringValue.
+func (p *literalPrinter) string(s string) {
+	p.b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			p.b.WriteString(`\"`)
+		case '\\':
+			p.b.WriteString(`\\`)
+		case '\b':
+			p.b.WriteString(`\b`)
+		case '\f':
+			p.b.WriteString(`\f`)
+		case '\n':
+			p.b.WriteString(`\n`)
+		case '\r':
+			p.b.WriteString(`\r`)
+		case '\t':
+			p.b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+				// Escape control characters and invalid UTF-8 (as U+FFFD).
+				fmt.Fprintf(&p.b, `\u%04x`, r)
+			} else {
+				p.b.WriteRune(r)
+			}
+		}
+	}
+	p.b.WriteByte('"')
+}
+
+// marshal prints rv by converting its encoding by "encoding/json".
+func (p *literalPrinter) marshal(rv reflect.Value) error {
+	b, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return err
+	}
+	return p.printJSON(b)
+}
+
+// printJSON converts JSON value b to a GraphQL literal.
+func (p *literalPrinter) printJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+	return p.printJSONValue(v)
+}
+
+func (p *literalPrinter) printJSONValue(v any) error {
+	switch v := v.(type) {
+	case nil:
+		p.b.WriteString("null")
+	case bool:
+		p.b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		p.b.WriteString(v.String())
+	case string:
+		p.string(v)
+	case []any:
+		p.b.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				p.b.WriteByte(',')
+			}
+			if err := p.printJSONValue(elem); err != nil {
+				return err
+			}
+		}
+		p.b.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		p.b.WriteByte('{')
+		for i, key := range keys {
+			if err := p.objectField(i, key); err != nil {
+				return err
+			}
+			if err := p.printJSONValue(v[key]); err != nil {
+				return err
+			}
+		}
+		p.b.WriteByte('}')
+	}
+	return nil
+}
+
+// Literal renders v as a GraphQL value literal, for example for use in arguments of struct tags. Strings are escaped as
+// per the GraphQL specification, values of Go types that implement Enum are rendered as enum values (i.e. unquoted), and
+// structs are rendered as input objects with the same field names as when they are encoded as variable values (see
+// Omittable). Values of Go types that map to custom scalars (see WithScalars) or that implement json.Marshaler or
+// encoding.TextMarshaler are rendered by converting their JSON encoding.
+// See https://spec.graphql.org/October2021/#Value.
+func Literal(v any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	return literal(v, o.scalars)
+}
+
+// Argument is an argument of a field. See Arg and Args.
+type Argument struct {
+	name  string
+	value any
+}
+
+// Arg returns an argument with the given name, whose value is rendered by Args.
+func Arg(name string, value any) Argument {
+	return Argument{
+		name:  name,
+		value: value,
+	}
+}
+
+// Args renders a field with arguments, which can be used as graphql tag of a dynamically constructed struct type
+// (see reflect.StructOf). The values of the arguments are rendered by Literal with options opts (e.g. WithScalars). For
+// example, Args("human", []Argument{Arg("id", "1000"), Arg("unit", unit)}) renders human(id:"1000",unit:METER) if unit
+// implements Enum.
+// Returns an error if field or the name of an argument is not a Name, or if the value of an argument cannot be rendered.
+func Args(field string, args []Argument, opts ...Option) (string, error) {
+	if !mapping.IsName(field) {
+		return "", fmt.Errorf(`invalid field name %#v`, field)
+	}
+	o := newOptions(opts)
+	p := literalPrinter{
+		scalars: o.scalars,
+	}
+	p.b.WriteString(field)
+	if len(args) == 0 {
+		return p.b.String(), nil
+	}
+	p.b.WriteByte('(')
+	for i, arg := range args {
+		if !mapping.IsName(arg.name) {
+			return "", fmt.Errorf(`invalid argument name %#v`, arg.name)
+		}
+		if i > 0 {
+			p.b.WriteByte(',')
+		}
+		p.b.WriteString(arg.name)
+		p.b.WriteByte(':')
+		if err := p.print(reflect.ValueOf(arg.value)); err != nil {
+			return "", fmt.Errorf(`argument %s: %w`, arg.name, err)
+		}
+	}
+	p.b.WriteByte(')')
+	return p.b.String(), nil
+}
+
+// literal prints v as a GraphQL value literal.
+func literal(v any, scalars *ScalarRegistry) (string, error) {
+	p := literalPrinter{
+		scalars: scalars,
+	}
+	if err := p.print(reflect.ValueOf(v)); err != nil {
+		return "", err
+	}
+	return p.b.String(), nil
+}
@@ -0,0 +1,166 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLengthUnit string
+
+func (u testLengthUnit) GraphQLEnum() string {
+	return string(u)
+}
+
+func Test_literal(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		s := "x"
+		actual, err := literal([]any{nil, (*string)(nil), &s, 1, -2.5, true, "a\"b\\c\n\x01é"}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `[null,null,"x",1,-2.5,true,"a\"b\\c\n\u0001é"]`, actual)
+		}
+	})
+	t.Run("Case2", func(t *testing.T) {
+		type embedded struct {
+			ZipCode string
+		}
+		actual, err := literal(struct {
+			FirstName string
+			Unit      testLengthUnit
+			Units     []testLengthUnit
+			Skipped   string `graphql:"-"`
+			Nickname  Omittable[string]
+			Bio       Omittable[*string]
+			Tags      map[string]int
+			embedded
+		}{
+			FirstName: "x",
+			Unit:      "METER",
+			Units:     []testLengthUnit{"FOOT"},
+			Bio:       OmittableNull[*string](),
+			Tags:      map[string]int{"b": 2, "a": 1},
+			embedded:  embedded{ZipCode: "1234"},
+		}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{firstName:"x",unit:METER,units:[FOOT],bio:null,tags:{a:1,b:2},zipCode:"1234"}`, actual)
+		}
+	})
+	t.Run("Case3", func(t *testing.T) {
+		actual, err := literal(struct {
+			ID   ID
+			JSON json.RawMessage `graphql:"json"`
+			Time time.Time
+			UUID [16]byte `graphql:"uuid"`
+		}{
+			ID:   ID{"1"},
+			JSON: json.RawMessage(`{"b":[1.5e3,"y"],"a":null}`),
+			Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			UUID: [16]byte{1},
+		}, defaultScalars)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{id:"1",json:{a:null,b:[1.5e3,"y"]},time:"2020-01-02T03:04:05Z",`+
+				`uuid:"01000000-0000-0000-0000-000000000000"}`, actual)
+		}
+	})
+	t.Run("Case4", func(t *testing.T) {
+		_, err := literal(testLengthUnit("1m"), nil)
+		assert.EqualError(t, err, `invalid enum value "1m" of Go type graphql.testLengthUnit`)
+	})
+	t.Run("Case5", func(t *testing.T) {
+		_, err := literal(map[string]int{"not-a-name": 1}, nil)
+		assert.EqualError(t, err, `invalid input object field name "not-a-name"`)
+	})
+	t.Run("Case6", func(t *testing.T) {
+		_, err := literal(math.NaN(), nil)
+		assert.ErrorContains(t, err, "unsupported value: NaN")
+	})
+	t.Run("TextMarshaler", func(t *testing.T) {
+		actual, err := literal(struct {
+			IP    netip.Addr
+			Color testRGB
+		}{
+			IP:    netip.MustParseAddr("1.2.3.4"),
+			Color: testRGB{B: 255},
+		}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{ip:"1.2.3.4",color:"#0000ff"}`, actual)
+		}
+	})
+}
+
+func Test_Literal(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		type Decimal struct {
+			s string
+		}
+		scalars := NewScalarRegistry()
+		RegisterScalar[Decimal](scalars, Scalar{
+			Name: "Decimal",
+			Marshal: func(v any) ([]byte, error) {
+				return []byte(v.(Decimal).s), nil
+			},
+		})
+		actual, err := Literal([]Decimal{{"1.50"}}, WithScalars(scalars))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `[1.50]`, actual)
+		}
+	})
+}
+
+func Test_Args(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual, err := Args("human", []Argument{Arg("id", "1\"000"), Arg("unit", testLengthUnit("METER"))})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `human(id:"1\"000",unit:METER)`, actual)
+		}
+	})
+	t.Run("Case2", func(t *testing.T) {
+		actual, err := Args("viewer", nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `viewer`, actual)
+		}
+	})
+	t.Run("Case3", func(t *testing.T) {
+		_, err := Args("human", []Argument{Arg("$id", 1)})
+		assert.EqualError(t, err, `invalid argument name "$id"`)
+		_, err = Args("human", []Argument{Arg("id", func() {})})
+		assert.ErrorContains(t, err, "argument id: json: unsupported type: func()")
+	})
+	t.Run("Case4", func(t *testing.T) {
+		tag, err := Args("human", []Argument{Arg("id", "1000")})
+		if !assert.NoError(t, err) {
+			return
+		}
+		q := reflect.New(reflect.StructOf([]reflect.StructField{{
+			Name: "Human",
+			Type: reflect.TypeOf(struct{ Name string }{}),
+			Tag:  reflect.StructTag(fmt.Sprintf(`graphql:%q`, tag)),
+		}})).Interface()
+		actual, err := ConstructQuery(q, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query{human(id:"1000"){name}}`, actual)
+		}
+	})
+	t.Run("Scalars", func(t *testing.T) {
+		type Decimal struct {
+			s string
+		}
+		scalars := NewScalarRegistry()
+		RegisterScalar[Decimal](scalars, Scalar{
+			Name: "Decimal",
+			Marshal: func(v any) ([]byte, error) {
+				return []byte(v.(Decimal).s), nil
+			},
+		})
+		actual, err := Args("convert", []Argument{Arg("amount", Decimal{"1.50"}), Arg("id", ID{"1"})}, WithScalars(scalars))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `convert(amount:1.50,id:"1")`, actual)
+		}
+	})
+}
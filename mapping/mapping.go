@@ -2,7 +2,9 @@
 package mapping
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/shurcooL/graphql/ident"
@@ -12,25 +14,213 @@ import (
 // both when constructing operations (queries/mutations) and when
 // unmarshalling the response.
 type FieldInfo struct {
-	inline  bool
-	graphQL string
+	inline       bool
+	graphQL      string
+	fragmentName string
+	includeIf    string
+	skipIf       string
+	// selection is the parsed graphQL, and is valid if parsed is true.
+	selection Selection
+	parsed    bool
+	depth     int
 }
 
-// NewFieldInfo maps a field of a Go struct to GraphQL.
+// TagError is returned if a struct tag of a Go struct field is invalid.
+type TagError struct {
+	// Type is the Go struct type that contains the field.
+	Type reflect.Type
+	// Field is the name of the Go struct field.
+	Field string
+	// Key is the key of the struct tag, e.g. "graphql".
+	Key string
+	// Tag is the value of the struct tag.
+	Tag string
+	Err error
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf(`invalid %s tag %#v of Go struct field %v.%s: %v`, e.Key, e.Tag, e.Type, e.Field, e.Err)
+}
+
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
+
+// NamedFragment is implemented by Go struct types that define a named fragment. Such types are emitted once as a fragment
+// definition (e.g. "fragment UserFields on User{...}") at the end of a document, and referenced by fragment spreads
+// (e.g. "...UserFields") wherever they are used.
+type NamedFragment interface {
+	// GraphQLFragment returns the name and type condition of the fragment, e.g. "UserFields" and "User".
+	// GraphQLFragment is called on a zero value.
+	GraphQLFragment() (name, typeCondition string)
+}
+
+var namedFragmentType = reflect.TypeOf((*NamedFragment)(nil)).Elem()
+
+// NewFieldInfo maps a field of a Go struct to GraphQL. The graphql tag of f is not validated, see ParseFieldInfo.
 func NewFieldInfo(f reflect.StructField) FieldInfo {
+	fieldInfo, _ := newFieldInfo(f)
+	return fieldInfo
+}
+
+// ParseFieldInfo is like NewFieldInfo, but returns a *TagError if the graphql tag of f is not a valid Field, InlineFragment
+// (without selection set) or FragmentSpread production (see ParseSelection), if the depth tag of f is not a positive
+// integer, or if f has a select tag that is not a valid SelectionSet production (see ParseSelectionSet), contains fragment
+// spreads or f is not a field. structType is the Go struct type that contains f. Blank graphql tags are not reported here, because they are
+// rejected when constructing operations.
+func ParseFieldInfo(structType reflect.Type, f reflect.StructField) (FieldInfo, error) {
+	fieldInfo, err := newFieldInfo(f)
+	if err != nil {
+		err.Type = structType
+		err.Field = f.Name
+		return FieldInfo{}, err
+	}
+	return fieldInfo, nil
+}
+
+func newFieldInfo(f reflect.StructField) (FieldInfo, *TagError) {
 	var fieldInfo FieldInfo
+	var tagErr *TagError
+	if depthTag, ok := f.Tag.Lookup("depth"); ok {
+		depth, err := strconv.Atoi(strings.TrimSpace(depthTag))
+		if err == nil && depth <= 0 {
+			err = fmt.Errorf(`depth must be positive`)
+		}
+		if err != nil {
+			tagErr = &TagError{Key: "depth", Tag: depthTag, Err: err}
+		}
+		fieldInfo.depth = depth
+	}
 	tag, hasTag := f.Tag.Lookup("graphql")
 	fieldInfo.inline = f.Anonymous && !hasTag
-	if !fieldInfo.inline {
-		if hasTag {
-			// TODO validate tag further (i.e. we need to remove commas, reject # chars)
-			fieldInfo.graphQL = tag
-		} else {
-			fieldInfo.graphQL = ident.ParseMixedCaps(f.Name).ToLowerCamelCase()
+	if fieldInfo.inline {
+		if name, _, ok := NamedFragmentOf(f.Type); ok {
+			// Embedded types that define named fragments are spread instead of inlined.
+			fieldInfo.inline = false
+			fieldInfo.graphQL = "..." + name
+			fieldInfo.fragmentName = name
+			fieldInfo.selection = Selection{Kind: FragmentSpreadSelection, Name: name}
+		}
+		fieldInfo.parsed = true
+	} else if hasTag {
+		fieldInfo.graphQL = tag
+		if strings.TrimSpace(tag) != "" {
+			var err error
+			fieldInfo.selection, err = ParseSelection(tag)
+			fieldInfo.parsed = err == nil
+			if err != nil {
+				tagErr = &TagError{Key: "graphql", Tag: tag, Err: err}
+			}
+			if fieldInfo.parsed && fieldInfo.selection.Kind == FragmentSpreadSelection {
+				fieldInfo.fragmentName = fieldInfo.selection.Name
+			}
 		}
+	} else {
+		fieldInfo.graphQL = ident.ParseMixedCaps(f.Name).ToLowerCamelCase()
+		fieldInfo.selection = Selection{Name: fieldInfo.graphQL}
+		fieldInfo.parsed = true
 	}
-	// TODO validate that type is a struct (wrapped by any amount of pointers) if it's an inline fragment.
-	return fieldInfo
+	fieldInfo.includeIf = strings.TrimPrefix(strings.TrimSpace(f.Tag.Get("include")), "$")
+	fieldInfo.skipIf = strings.TrimPrefix(strings.TrimSpace(f.Tag.Get("skip")), "$")
+	if fieldInfo.includeIf != "" || fieldInfo.skipIf != "" {
+		if fieldInfo.inline {
+			// Directives cannot be applied to inlined fields, so use an inline fragment without type condition instead.
+			fieldInfo.inline = false
+			fieldInfo.graphQL = "..."
+			fieldInfo.selection = Selection{Kind: InlineFragmentSelection}
+		}
+		if fieldInfo.includeIf != "" {
+			fieldInfo.graphQL += " @include(if: $" + fieldInfo.includeIf + ")"
+			fieldInfo.selection.Directives = append(fieldInfo.selection.Directives, conditionDirective("include",
+				fieldInfo.includeIf))
+		}
+		if fieldInfo.skipIf != "" {
+			fieldInfo.graphQL += " @skip(if: $" + fieldInfo.skipIf + ")"
+			fieldInfo.selection.Directives = append(fieldInfo.selection.Directives, conditionDirective("skip",
+				fieldInfo.skipIf))
+		}
+	}
+	if selectTag, ok := f.Tag.Lookup("select"); ok && tagErr == nil {
+		var err error
+		if fieldInfo.inline || fieldInfo.selection.Kind != FieldSelection {
+			err = fmt.Errorf(`select tag requires a graphql tag that is a field`)
+		} else if fieldInfo.selection.SelectionSet, err = ParseSelectionSet(selectTag); err == nil &&
+			hasFragmentSpread(fieldInfo.selection.SelectionSet) {
+			// The fragment definitions of such spreads would never be written.
+			err = fmt.Errorf(`fragment spreads are not allowed in select tags`)
+		}
+		if err != nil {
+			tagErr = &TagError{Key: "select", Tag: selectTag, Err: err}
+		}
+	}
+	return fieldInfo, tagErr
+}
+
+// hasFragmentSpread returns true if set (or a nested selection set) contains a fragment spread.
+func hasFragmentSpread(set []Selection) bool {
+	for _, sel := range set {
+		if sel.Kind == FragmentSpreadSelection || hasFragmentSpread(sel.SelectionSet) {
+			return true
+		}
+	}
+	return false
+}
+
+func conditionDirective(name, variable string) Directive {
+	return Directive{
+		Name: name,
+		Arguments: []Argument{{
+			Name: "if",
+			Value: Value{
+				Kind:     VariableValue,
+				Raw:      "$" + variable,
+				Variable: variable,
+			},
+		}},
+	}
+}
+
+// NamedFragmentOf returns the name and type condition of the named fragment defined by t, if t is a struct type (wrapped by
+// any amount of pointers) that implements NamedFragment (with a value or pointer receiver).
+// A struct type that implements NamedFragment only because it embeds a type that defines the same fragment does not define a
+// named fragment itself.
+func NamedFragmentOf(t reflect.Type) (name, typeCondition string, ok bool) {
+	t = UnwrapPointerType(t)
+	if t.Kind() != reflect.Struct || !reflect.PointerTo(t).Implements(namedFragmentType) {
+		return "", "", false
+	}
+	name, typeCondition = reflect.New(t).Interface().(NamedFragment).GraphQLFragment()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous {
+			if embeddedName, _, ok := NamedFragmentOf(f.Type); ok && embeddedName == name {
+				// The GraphQLFragment method is promoted from f.
+				return "", "", false
+			}
+		}
+	}
+	return name, typeCondition, true
+}
+
+// IsName returns true if s is a Name token. See https://spec.graphql.org/October2021/#Name.
+func IsName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isNameByte(s[i], i == 0) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (!first && '0' <= c && c <= '9')
+}
+
+// UnwrapPointerType returns the type that t points to, through any amount of pointers, or t if t is not a pointer type.
+func UnwrapPointerType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
 }
 
 // IsInlineFragment returns true if f.GraphQL() is an InlineFragment production
@@ -38,7 +228,37 @@ func NewFieldInfo(f reflect.StructField) FieldInfo {
 // an inline fragment.
 // See https://spec.graphql.org/October2021/#sec-Selection-Sets.
 func (f FieldInfo) IsInlineFragment() bool {
-	return strings.HasPrefix(strings.TrimSpace(f.graphQL), "...")
+	if f.parsed {
+		return !f.inline && f.selection.Kind == InlineFragmentSelection
+	}
+	return f.fragmentName == "" && strings.HasPrefix(strings.TrimSpace(f.graphQL), "...")
+}
+
+// IsFragmentSpread returns true if f.GraphQL() is a FragmentSpread production, e.g. "...UserFields".
+// I.e. the field of the Go struct defines a named fragment. See FragmentName and NamedFragment.
+// See https://spec.graphql.org/October2021/#FragmentSpread.
+func (f FieldInfo) IsFragmentSpread() bool {
+	return f.fragmentName != ""
+}
+
+// FragmentName returns the name of the fragment that is spread if f.IsFragmentSpread() is true, and an empty string
+// otherwise.
+func (f FieldInfo) FragmentName() string {
+	return f.fragmentName
+}
+
+// IncludeIf returns the name of the variable of the @include directive defined by the "include" struct tag, or an empty
+// string if the field has no such tag. For example, the struct tag `include:"withEmail"` appends
+// " @include(if: $withEmail)" to f.GraphQL().
+// See https://spec.graphql.org/October2021/#sec--include.
+func (f FieldInfo) IncludeIf() string {
+	return f.includeIf
+}
+
+// SkipIf is like IncludeIf, but for the @skip directive defined by the "skip" struct tag.
+// See https://spec.graphql.org/October2021/#sec--skip.
+func (f FieldInfo) SkipIf() string {
+	return f.skipIf
 }
 
 // GraphQL returns a GraphQL snippet. Recall that Go structs correspond to selection sets
@@ -51,12 +271,17 @@ func (f FieldInfo) GraphQL() string {
 	return f.graphQL
 }
 
-// FieldName names the field in GraphQL.
-// Aliases are not supported.
+// FieldName names the field in GraphQL responses, which is the alias of the field if it has one.
 func (f FieldInfo) FieldName() string {
-	if f.Inline() || f.IsInlineFragment() {
+	if f.Inline() || f.IsInlineFragment() || f.IsFragmentSpread() {
 		return ""
 	}
+	if f.parsed {
+		if f.selection.Alias != "" {
+			return f.selection.Alias
+		}
+		return f.selection.Name
+	}
 	graphQL := strings.TrimSpace(f.graphQL)
 	if i := strings.IndexAny(graphQL, "(:@"); i >= 0 {
 		return strings.TrimSpace(graphQL[:i])
@@ -70,3 +295,53 @@ func (f FieldInfo) FieldName() string {
 func (f FieldInfo) Inline() bool {
 	return f.inline
 }
+
+// Alias returns the alias of the field, or an empty string if the field has no alias or f is not a field.
+func (f FieldInfo) Alias() string {
+	return f.selection.Alias
+}
+
+// Name returns the name of the field (not its alias), the name of the fragment if f.IsFragmentSpread() is true, or an
+// empty string otherwise.
+func (f FieldInfo) Name() string {
+	return f.selection.Name
+}
+
+// TypeCondition returns the type condition if f.IsInlineFragment() is true, or an empty string otherwise.
+func (f FieldInfo) TypeCondition() string {
+	return f.selection.TypeCondition
+}
+
+// Arguments returns the arguments of the field.
+func (f FieldInfo) Arguments() []Argument {
+	return f.selection.Arguments
+}
+
+// Directives returns the directives, including the directives defined by the "include" and "skip" struct tags.
+func (f FieldInfo) Directives() []Directive {
+	return f.selection.Directives
+}
+
+// Variables returns the names of the variables used by the arguments and directives, in order of appearance.
+func (f FieldInfo) Variables() []string {
+	if !f.parsed {
+		// The graphql tag is not valid GraphQL, see NewFieldInfo.
+		return scanVariables(f.graphQL)
+	}
+	return f.selection.Variables()
+}
+
+// SelectionSet returns the selection set defined by the select struct tag, or nil if the field has no (valid) select tag.
+// The selection set is written instead of the selection set defined by the Go type of the field, which is useful for fields
+// whose Go types receive JSON values as a whole, e.g.
+// "Settings json.RawMessage `graphql:"settings" select:"{theme locale}"`".
+func (f FieldInfo) SelectionSet() []Selection {
+	return f.selection.SelectionSet
+}
+
+// Depth returns the maximum number of times the field occurs in a path of nested selections as defined by the depth struct
+// tag, or 0 if the field has no depth tag. For example, the depth tag of the Replies field of
+// "type Comment struct { Replies []Comment `depth:"3"` }" bounds the recursion of the selection set of Comment.
+func (f FieldInfo) Depth() int {
+	return f.depth
+}
@@ -43,3 +43,98 @@ func Test_NewFieldInfo(t *testing.T) {
 		assert.Equal(t, "bio", actual.graphQL)
 	})
 }
+
+type userFields struct {
+	Name string
+}
+
+func (userFields) GraphQLFragment() (name, typeCondition string) {
+	return "UserFields", "User"
+}
+
+func Test_NewFieldInfo_FragmentSpread(t *testing.T) {
+	t.Run("Tag", func(t *testing.T) {
+		rt := reflect.TypeOf(struct {
+			User struct {
+				Bio string
+			} `graphql:"... UserFields"`
+		}{})
+		actual := NewFieldInfo(rt.Field(0))
+		assert.True(t, actual.IsFragmentSpread())
+		assert.False(t, actual.IsInlineFragment())
+		assert.Equal(t, "UserFields", actual.FragmentName())
+		assert.Equal(t, "", actual.FieldName())
+	})
+	t.Run("InlineFragmentWithoutTypeCondition", func(t *testing.T) {
+		rt := reflect.TypeOf(struct {
+			User struct {
+				Bio string
+			} `graphql:"... @include(if: $x)"`
+		}{})
+		actual := NewFieldInfo(rt.Field(0))
+		assert.False(t, actual.IsFragmentSpread())
+		assert.True(t, actual.IsInlineFragment())
+	})
+	t.Run("NamedFragment", func(t *testing.T) {
+		rt := reflect.TypeOf(struct {
+			*userFields
+		}{})
+		actual := NewFieldInfo(rt.Field(0))
+		assert.False(t, actual.Inline())
+		assert.True(t, actual.IsFragmentSpread())
+		assert.Equal(t, "...UserFields", actual.GraphQL())
+	})
+}
+
+func Test_NamedFragmentOf(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		name, typeCondition, ok := NamedFragmentOf(reflect.TypeOf(&userFields{}))
+		assert.True(t, ok)
+		assert.Equal(t, "UserFields", name)
+		assert.Equal(t, "User", typeCondition)
+	})
+	t.Run("Case2", func(t *testing.T) {
+		_, _, ok := NamedFragmentOf(reflect.TypeOf(""))
+		assert.False(t, ok)
+	})
+}
+
+func Test_IsName(t *testing.T) {
+	for _, c := range []struct {
+		s        string
+		expected bool
+	}{
+		{"", false},
+		{"_a1", true},
+		{"User", true},
+		{"1a", false},
+		{"a-b", false},
+	} {
+		assert.Equal(t, c.expected, IsName(c.s), c.s)
+	}
+}
+
+func Test_NewFieldInfo_Conditions(t *testing.T) {
+	t.Run("Field", func(t *testing.T) {
+		rt := reflect.TypeOf(struct {
+			Email string `graphql:"email" include:"$withEmail" skip:"noEmail"`
+		}{})
+		actual := NewFieldInfo(rt.Field(0))
+		assert.Equal(t, "email @include(if: $withEmail) @skip(if: $noEmail)", actual.GraphQL())
+		assert.Equal(t, "email", actual.FieldName())
+		assert.Equal(t, "withEmail", actual.IncludeIf())
+		assert.Equal(t, "noEmail", actual.SkipIf())
+	})
+	t.Run("Embedded", func(t *testing.T) {
+		type Fields struct {
+			Email string
+		}
+		rt := reflect.TypeOf(struct {
+			Fields `include:"withEmail"`
+		}{})
+		actual := NewFieldInfo(rt.Field(0))
+		assert.False(t, actual.Inline())
+		assert.True(t, actual.IsInlineFragment())
+		assert.Equal(t, "... @include(if: $withEmail)", actual.GraphQL())
+	})
+}
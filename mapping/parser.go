@@ -0,0 +1,704 @@
+package mapping
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectionKind is the kind of selection defined by a graphql tag.
+type SelectionKind int
+
+const (
+	// FieldSelection is a Field production, e.g. "alias: name(id: $id) @include(if: $x)".
+	// See https://spec.graphql.org/October2021/#Field.
+	FieldSelection SelectionKind = iota
+	// InlineFragmentSelection is an InlineFragment production without selection set, e.g. "... on User".
+	// See https://spec.graphql.org/October2021/#InlineFragment.
+	InlineFragmentSelection
+	// FragmentSpreadSelection is a FragmentSpread production, e.g. "...UserFields".
+	// See https://spec.graphql.org/October2021/#FragmentSpread.
+	FragmentSpreadSelection
+)
+
+// Selection is a parsed graphql tag, i.e. a selection without its selection set, or a selection of a select tag (see
+// ParseSelectionSet).
+type Selection struct {
+	Kind SelectionKind
+
+	// Alias is the alias of a field, or empty if the field has no alias.
+	Alias string
+
+	// Name is the name of a field, or the name of the fragment of a fragment spread.
+	Name string
+
+	// TypeCondition is the type condition of an inline fragment, or empty if the inline fragment has no type condition.
+	TypeCondition string
+
+	// Arguments are the arguments of a field.
+	Arguments []Argument
+
+	Directives []Directive
+
+	// SelectionSet is the selection set of a selection parsed by ParseSelectionSet, or nil if the selection has no selection
+	// set. Selections parsed by ParseSelection never have a selection set.
+	SelectionSet []Selection
+}
+
+// Argument is an argument of a field or directive, e.g. "id: $id". Argument is also used for the fields of input object
+// values.
+type Argument struct {
+	Name  string
+	Value Value
+}
+
+// Directive is a directive, e.g. "@include(if: $withEmail)".
+type Directive struct {
+	Name      string
+	Arguments []Argument
+}
+
+// ValueKind is the kind of a Value.
+type ValueKind int
+
+const (
+	VariableValue ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+// Value is a value of an argument. See https://spec.graphql.org/October2021/#Value.
+type Value struct {
+	Kind ValueKind
+
+	// Raw is the source text of the value, e.g. `"1000"`, "METER", "$id" or "[1, $x]".
+	Raw string
+
+	// Variable is the name of the variable if Kind is VariableValue, e.g. "id" for "$id".
+	Variable string
+
+	// List are the elements if Kind is ListValue.
+	List []Value
+
+	// Fields are the fields if Kind is ObjectValue.
+	Fields []Argument
+}
+
+// Type is a type reference, e.g. "[String!]!". See https://spec.graphql.org/October2021/#Type.
+type Type struct {
+	// Name is the name of a named type, or empty if the type is a list type.
+	Name string
+
+	// Elem is the type of the elements of a list type, or nil if the type is a named type.
+	Elem *Type
+
+	NonNull bool
+}
+
+// SyntaxError is a syntax error in a graphql tag.
+type SyntaxError struct {
+	// Offset is the byte offset in the tag where the error occurred.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf(`%s at offset %d`, e.Msg, e.Offset)
+}
+
+// ParseSelection parses a graphql tag, which is a Field, InlineFragment (without selection set) or FragmentSpread
+// production. Unlike GraphQL documents, tags must not contain comments, because tags are written on a single line in
+// compact documents.
+func ParseSelection(s string) (Selection, error) {
+	p := parser{
+		lexer: lexer{s: s},
+	}
+	if err := p.next(); err != nil {
+		return Selection{}, err
+	}
+	sel, err := p.selection()
+	if err != nil {
+		return Selection{}, err
+	}
+	if p.tok.kind != tokenEOF {
+		return Selection{}, p.unexpected()
+	}
+	return sel, nil
+}
+
+// ParseType parses the type of a variable declared by a struct tag, e.g. "[String!]!".
+// See https://spec.graphql.org/October2021/#Type.
+func ParseType(s string) (Type, error) {
+	p := parser{
+		lexer: lexer{s: s},
+	}
+	if err := p.next(); err != nil {
+		return Type{}, err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return Type{}, err
+	}
+	if p.tok.kind != tokenEOF {
+		return Type{}, p.unexpected()
+	}
+	return typ, nil
+}
+
+// ParseConstValue parses the default value of a variable declared by a struct tag, e.g. "[METER, FOOT]". Unlike the values
+// of arguments, the value must not contain variables.
+// See https://spec.graphql.org/October2021/#Value.
+func ParseConstValue(s string) (Value, error) {
+	p := parser{
+		lexer:    lexer{s: s},
+		constant: true,
+	}
+	if err := p.next(); err != nil {
+		return Value{}, err
+	}
+	v, err := p.value()
+	if err != nil {
+		return Value{}, err
+	}
+	if p.tok.kind != tokenEOF {
+		return Value{}, p.unexpected()
+	}
+	return v, nil
+}
+
+// ParseSelectionSet parses a select tag, which is a SelectionSet production, e.g. "{theme locale}". Like graphql tags,
+// select tags must not contain comments.
+// See https://spec.graphql.org/October2021/#SelectionSet.
+func ParseSelectionSet(s string) ([]Selection, error) {
+	p := parser{
+		lexer: lexer{s: s},
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	set, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return set, nil
+}
+
+// Variables returns the names of the variables used by the arguments and directives of s (including those of the
+// selection set of s), in order of appearance.
+func (s Selection) Variables() []string {
+	return s.appendVariables(nil)
+}
+
+func (s Selection) appendVariables(names []string) []string {
+	for _, a := range s.Arguments {
+		names = a.Value.appendVariables(names)
+	}
+	for _, d := range s.Directives {
+		for _, a := range d.Arguments {
+			names = a.Value.appendVariables(names)
+		}
+	}
+	for _, sel := range s.SelectionSet {
+		names = sel.appendVariables(names)
+	}
+	return names
+}
+
+func (v Value) appendVariables(names []string) []string {
+	switch v.Kind {
+	case VariableValue:
+		names = append(names, v.Variable)
+	case ListValue:
+		for _, elem := range v.List {
+			names = elem.appendVariables(names)
+		}
+	case ObjectValue:
+		for _, f := range v.Fields {
+			names = f.Value.appendVariables(names)
+		}
+	}
+	return names
+}
+
+// scanVariables returns the names of the variables referenced by s (e.g. "id" and "unit" for
+// "human(id: $id) { height(unit: $unit) }"), in order of appearance. Unlike the parser, scanVariables accepts any sequence of
+// tokens, so it also works for tags that are not valid GraphQL. Scanning stops at the first invalid token (e.g. a comment).
+func scanVariables(s string) (names []string) {
+	l := lexer{s: s}
+	var prev token
+	for {
+		tok, err := l.next()
+		if err != nil || tok.kind == tokenEOF {
+			return
+		}
+		if tok.kind == tokenName && prev.kind == tokenPunctuator && prev.text == "$" && tok.offset == prev.offset+1 {
+			names = append(names, tok.text)
+		}
+		prev = tok
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+// lexer splits a graphql tag into tokens. See https://spec.graphql.org/October2021/#sec-Language.Source-Text.
+type lexer struct {
+	s string
+	i int
+}
+
+func (l *lexer) errorf(offset int, format string, args ...any) error {
+	return &SyntaxError{
+		Offset: offset,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	// Skip ignored tokens. See https://spec.graphql.org/October2021/#sec-Language.Source-Text.Ignored-Tokens.
+	for l.i < len(l.s) {
+		c := l.s[l.i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.i++
+		} else if strings.HasPrefix(l.s[l.i:], "\ufeff") {
+			// Skip the byte order mark.
+			l.i += len("\ufeff")
+		} else {
+			break
+		}
+	}
+	start := l.i
+	if start == len(l.s) {
+		return token{kind: tokenEOF, offset: start}, nil
+	}
+	c := l.s[start]
+	switch {
+	case c == '#':
+		return token{}, l.errorf(start, `comments are not allowed`)
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		l.i++
+		return token{kind: tokenPunctuator, text: l.s[start:l.i], offset: start}, nil
+	case c == '.':
+		if !strings.HasPrefix(l.s[start:], "...") {
+			return token{}, l.errorf(start, `unexpected character '.'`)
+		}
+		l.i += 3
+		return token{kind: tokenPunctuator, text: "...", offset: start}, nil
+	case isNameByte(c, true):
+		for l.i < len(l.s) && isNameByte(l.s[l.i], false) {
+			l.i++
+		}
+		return token{kind: tokenName, text: l.s[start:l.i], offset: start}, nil
+	case c == '-' || ('0' <= c && c <= '9'):
+		return l.number()
+	case c == '"':
+		if strings.HasPrefix(l.s[start:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	}
+	return token{}, l.errorf(start, `unexpected character %q`, c)
+}
+
+// number lexes an IntValue or FloatValue. See https://spec.graphql.org/October2021/#sec-Int-Value.
+func (l *lexer) number() (token, error) {
+	start := l.i
+	kind := tokenInt
+	if l.s[l.i] == '-' {
+		l.i++
+	}
+	if l.i < len(l.s) && l.s[l.i] == '0' {
+		l.i++
+		if l.i < len(l.s) && '0' <= l.s[l.i] && l.s[l.i] <= '9' {
+			return token{}, l.errorf(l.i, `invalid number (leading zero)`)
+		}
+	} else if n := l.digits(); n == 0 {
+		return token{}, l.errorf(l.i, `invalid number (expected digit)`)
+	}
+	if l.i < len(l.s) && l.s[l.i] == '.' {
+		kind = tokenFloat
+		l.i++
+		if l.digits() == 0 {
+			return token{}, l.errorf(l.i, `invalid number (expected digit)`)
+		}
+	}
+	if l.i < len(l.s) && (l.s[l.i] == 'e' || l.s[l.i] == 'E') {
+		kind = tokenFloat
+		l.i++
+		if l.i < len(l.s) && (l.s[l.i] == '+' || l.s[l.i] == '-') {
+			l.i++
+		}
+		if l.digits() == 0 {
+			return token{}, l.errorf(l.i, `invalid number (expected digit)`)
+		}
+	}
+	if l.i < len(l.s) && (l.s[l.i] == '.' || isNameByte(l.s[l.i], true)) {
+		return token{}, l.errorf(l.i, `invalid number (unexpected character %q)`, l.s[l.i])
+	}
+	return token{kind: kind, text: l.s[start:l.i], offset: start}, nil
+}
+
+func (l *lexer) digits() int {
+	start := l.i
+	for l.i < len(l.s) && '0' <= l.s[l.i] && l.s[l.i] <= '9' {
+		l.i++
+	}
+	return l.i - start
+}
+
+// string lexes a StringValue that is not a block string. See https://spec.graphql.org/October2021/#StringValue.
+func (l *lexer) string() (token, error) {
+	start := l.i
+	l.i++
+	for l.i < len(l.s) {
+		switch c := l.s[l.i]; c {
+		case '"':
+			l.i++
+			return token{kind: tokenString, text: l.s[start:l.i], offset: start}, nil
+		case '\n', '\r':
+			return token{}, l.errorf(l.i, `unterminated string`)
+		case '\\':
+			l.i++
+			if l.i == len(l.s) {
+				break
+			}
+			switch l.s[l.i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				l.i++
+			case 'u':
+				l.i++
+				for j := 0; j < 4; j++ {
+					if l.i == len(l.s) || !isHexDigit(l.s[l.i]) {
+						return token{}, l.errorf(l.i, `invalid unicode escape sequence`)
+					}
+					l.i++
+				}
+			default:
+				return token{}, l.errorf(l.i-1, `invalid escape sequence`)
+			}
+		default:
+			l.i++
+		}
+	}
+	return token{}, l.errorf(start, `unterminated string`)
+}
+
+// blockString lexes a block string. See https://spec.graphql.org/October2021/#BlockString.
+func (l *lexer) blockString() (token, error) {
+	start := l.i
+	l.i += 3
+	for l.i < len(l.s) {
+		if strings.HasPrefix(l.s[l.i:], `\"""`) {
+			l.i += 4
+		} else if strings.HasPrefix(l.s[l.i:], `"""`) {
+			l.i += 3
+			return token{kind: tokenString, text: l.s[start:l.i], offset: start}, nil
+		} else {
+			l.i++
+		}
+	}
+	return token{}, l.errorf(start, `unterminated block string`)
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// parser parses the tokens of a graphql tag.
+type parser struct {
+	lexer lexer
+	tok   token
+	// end is the offset of the end of the previous token.
+	end int
+	// constant is true if values must not contain variables.
+	constant bool
+}
+
+func (p *parser) next() error {
+	p.end = p.lexer.i
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return p.lexer.errorf(p.tok.offset, `unexpected end of tag`)
+	}
+	return p.lexer.errorf(p.tok.offset, `unexpected %#v`, p.tok.text)
+}
+
+func (p *parser) isPunctuator(text string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.text == text
+}
+
+func (p *parser) expectPunctuator(text string) error {
+	if !p.isPunctuator(text) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.text
+	return name, p.next()
+}
+
+func (p *parser) selection() (sel Selection, err error) {
+	if p.isPunctuator("...") {
+		if err = p.next(); err != nil {
+			return
+		}
+		switch {
+		case p.tok.kind == tokenName && p.tok.text == "on":
+			sel.Kind = InlineFragmentSelection
+			if err = p.next(); err != nil {
+				return
+			}
+			if sel.TypeCondition, err = p.name(); err != nil {
+				return
+			}
+		case p.tok.kind == tokenName:
+			sel.Kind = FragmentSpreadSelection
+			if sel.Name, err = p.name(); err != nil {
+				return
+			}
+		default:
+			sel.Kind = InlineFragmentSelection
+		}
+	} else {
+		sel.Kind = FieldSelection
+		if sel.Name, err = p.name(); err != nil {
+			return
+		}
+		if p.isPunctuator(":") {
+			if err = p.next(); err != nil {
+				return
+			}
+			sel.Alias = sel.Name
+			if sel.Name, err = p.name(); err != nil {
+				return
+			}
+		}
+		if sel.Arguments, err = p.arguments(); err != nil {
+			return
+		}
+	}
+	sel.Directives, err = p.directives()
+	return
+}
+
+// selectionSet parses a selection set. Fields may have selection sets, inline fragments must have selection sets and fragment
+// spreads must not have selection sets.
+func (p *parser) selectionSet() (set []Selection, err error) {
+	if err = p.expectPunctuator("{"); err != nil {
+		return
+	}
+	for {
+		var sel Selection
+		if sel, err = p.selection(); err != nil {
+			return nil, err
+		}
+		if p.isPunctuator("{") && sel.Kind != FragmentSpreadSelection {
+			if sel.SelectionSet, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+		} else if sel.Kind == InlineFragmentSelection {
+			return nil, p.unexpected()
+		}
+		set = append(set, sel)
+		if p.isPunctuator("}") {
+			return set, p.next()
+		}
+	}
+}
+
+// arguments parses optional arguments. See https://spec.graphql.org/October2021/#Arguments.
+func (p *parser) arguments() (args []Argument, err error) {
+	if !p.isPunctuator("(") {
+		return nil, nil
+	}
+	if err = p.next(); err != nil {
+		return
+	}
+	for {
+		var arg Argument
+		if arg.Name, err = p.name(); err != nil {
+			return
+		}
+		if err = p.expectPunctuator(":"); err != nil {
+			return
+		}
+		if arg.Value, err = p.value(); err != nil {
+			return
+		}
+		args = append(args, arg)
+		if p.isPunctuator(")") {
+			return args, p.next()
+		}
+	}
+}
+
+// directives parses optional directives. See https://spec.graphql.org/October2021/#Directives.
+func (p *parser) directives() (directives []Directive, err error) {
+	for p.isPunctuator("@") {
+		if err = p.next(); err != nil {
+			return
+		}
+		var d Directive
+		if d.Name, err = p.name(); err != nil {
+			return
+		}
+		if d.Arguments, err = p.arguments(); err != nil {
+			return
+		}
+		directives = append(directives, d)
+	}
+	return
+}
+
+// typeRef parses a type reference. See https://spec.graphql.org/October2021/#Type.
+func (p *parser) typeRef() (t Type, err error) {
+	if p.isPunctuator("[") {
+		if err = p.next(); err != nil {
+			return
+		}
+		var elem Type
+		if elem, err = p.typeRef(); err != nil {
+			return
+		}
+		t.Elem = &elem
+		if err = p.expectPunctuator("]"); err != nil {
+			return
+		}
+	} else if t.Name, err = p.name(); err != nil {
+		return
+	}
+	if p.isPunctuator("!") {
+		t.NonNull = true
+		err = p.next()
+	}
+	return
+}
+
+// value parses a value. See https://spec.graphql.org/October2021/#Value.
+func (p *parser) value() (v Value, err error) {
+	start := p.tok.offset
+	switch p.tok.kind {
+	case tokenPunctuator:
+		switch p.tok.text {
+		case "$":
+			if p.constant {
+				return v, p.lexer.errorf(start, `variables are not allowed in constant values`)
+			}
+			v.Kind = VariableValue
+			if err = p.next(); err != nil {
+				return
+			}
+			if p.tok.offset != start+1 {
+				// Ignored tokens are not allowed between "$" and the name.
+				return v, p.unexpected()
+			}
+			if v.Variable, err = p.name(); err != nil {
+				return
+			}
+		case "[":
+			v.Kind = ListValue
+			if err = p.next(); err != nil {
+				return
+			}
+			for !p.isPunctuator("]") {
+				var elem Value
+				if elem, err = p.value(); err != nil {
+					return
+				}
+				v.List = append(v.List, elem)
+			}
+			if err = p.next(); err != nil {
+				return
+			}
+		case "{":
+			v.Kind = ObjectValue
+			if err = p.next(); err != nil {
+				return
+			}
+			for !p.isPunctuator("}") {
+				var f Argument
+				if f.Name, err = p.name(); err != nil {
+					return
+				}
+				if err = p.expectPunctuator(":"); err != nil {
+					return
+				}
+				if f.Value, err = p.value(); err != nil {
+					return
+				}
+				v.Fields = append(v.Fields, f)
+			}
+			if err = p.next(); err != nil {
+				return
+			}
+		default:
+			return v, p.unexpected()
+		}
+	case tokenName:
+		switch p.tok.text {
+		case "true", "false":
+			v.Kind = BooleanValue
+		case "null":
+			v.Kind = NullValue
+		default:
+			v.Kind = EnumValue
+		}
+		if err = p.next(); err != nil {
+			return
+		}
+	case tokenInt:
+		v.Kind = IntValue
+		if err = p.next(); err != nil {
+			return
+		}
+	case tokenFloat:
+		v.Kind = FloatValue
+		if err = p.next(); err != nil {
+			return
+		}
+	case tokenString:
+		v.Kind = StringValue
+		if err = p.next(); err != nil {
+			return
+		}
+	default:
+		return v, p.unexpected()
+	}
+	v.Raw = p.lexer.s[start:p.end]
+	return v, nil
+}
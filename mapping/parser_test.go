@@ -0,0 +1,257 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseSelection(t *testing.T) {
+	t.Run("Field", func(t *testing.T) {
+		actual, err := ParseSelection(`smallPic: profilePic(size: 64, ids: [$a, "x"], input: {unit: METER, b: null}) ` +
+			`@include(if: $withPic)`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Selection{
+				Kind:  FieldSelection,
+				Alias: "smallPic",
+				Name:  "profilePic",
+				Arguments: []Argument{
+					{Name: "size", Value: Value{Kind: IntValue, Raw: "64"}},
+					{Name: "ids", Value: Value{Kind: ListValue, Raw: `[$a, "x"]`, List: []Value{
+						{Kind: VariableValue, Raw: "$a", Variable: "a"},
+						{Kind: StringValue, Raw: `"x"`},
+					}}},
+					{Name: "input", Value: Value{Kind: ObjectValue, Raw: `{unit: METER, b: null}`, Fields: []Argument{
+						{Name: "unit", Value: Value{Kind: EnumValue, Raw: "METER"}},
+						{Name: "b", Value: Value{Kind: NullValue, Raw: "null"}},
+					}}},
+				},
+				Directives: []Directive{{Name: "include", Arguments: []Argument{
+					{Name: "if", Value: Value{Kind: VariableValue, Raw: "$withPic", Variable: "withPic"}},
+				}}},
+			}, actual)
+			assert.Equal(t, []string{"a", "withPic"}, actual.Variables())
+		}
+	})
+	t.Run("InlineFragment", func(t *testing.T) {
+		actual, err := ParseSelection(`... on User @skip(if: true)`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, InlineFragmentSelection, actual.Kind)
+			assert.Equal(t, "User", actual.TypeCondition)
+			assert.Equal(t, "skip", actual.Directives[0].Name)
+			assert.Equal(t, BooleanValue, actual.Directives[0].Arguments[0].Value.Kind)
+		}
+		actual, err = ParseSelection(`...`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Selection{Kind: InlineFragmentSelection}, actual)
+		}
+	})
+	t.Run("FragmentSpread", func(t *testing.T) {
+		actual, err := ParseSelection(`... UserFields`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Selection{Kind: FragmentSpreadSelection, Name: "UserFields"}, actual)
+		}
+	})
+	t.Run("Strings", func(t *testing.T) {
+		actual, err := ParseSelection(`f(a: "$x \" é", b: """ $y \""" $z """, c: -1.5e3, d: $d)`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"d"}, actual.Variables())
+			assert.Equal(t, FloatValue, actual.Arguments[2].Value.Kind)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		for _, c := range []struct {
+			tag string
+			err string
+		}{
+			{`name # comment`, `comments are not allowed at offset 5`},
+			{`name(id: $id`, `unexpected end of tag at offset 12`},
+			{`name(id: "x)`, `unterminated string at offset 9`},
+			{`name(id: 01)`, `invalid number (leading zero) at offset 10`},
+			{`name(id: "\x")`, `invalid escape sequence at offset 10`},
+			{`name(id: $ id)`, `unexpected "id" at offset 11`},
+			{`name { id }`, `unexpected "{" at offset 5`},
+			{`name.id`, `unexpected character '.' at offset 4`},
+			{`... on`, `unexpected end of tag at offset 6`},
+			{`name()`, `unexpected ")" at offset 5`},
+		} {
+			_, err := ParseSelection(c.tag)
+			assert.EqualError(t, err, c.err, c.tag)
+		}
+	})
+}
+
+func Test_ParseType(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual, err := ParseType(`[ [String!] ]!`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Type{
+				Elem: &Type{
+					Elem: &Type{Name: "String", NonNull: true},
+				},
+				NonNull: true,
+			}, actual)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		for _, c := range []struct {
+			tag string
+			err string
+		}{
+			{``, `unexpected end of tag at offset 0`},
+			{`Int!!`, `unexpected "!" at offset 4`},
+			{`[Int`, `unexpected end of tag at offset 4`},
+			{`Int Float`, `unexpected "Float" at offset 4`},
+		} {
+			_, err := ParseType(c.tag)
+			assert.EqualError(t, err, c.err, c.tag)
+		}
+	})
+}
+
+func Test_ParseConstValue(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual, err := ParseConstValue(`[METER, FOOT]`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Value{
+				Kind: ListValue,
+				Raw:  "[METER, FOOT]",
+				List: []Value{{Kind: EnumValue, Raw: "METER"}, {Kind: EnumValue, Raw: "FOOT"}},
+			}, actual)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		for _, c := range []struct {
+			tag string
+			err string
+		}{
+			{`abc def (`, `unexpected "def" at offset 4`},
+			{`{a: $x}`, `variables are not allowed in constant values at offset 4`},
+			{`[1`, `unexpected end of tag at offset 2`},
+		} {
+			_, err := ParseConstValue(c.tag)
+			assert.EqualError(t, err, c.err, c.tag)
+		}
+	})
+}
+
+func Test_ParseSelectionSet(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual, err := ParseSelectionSet(`{theme, locale: language(format: $format) { code } ... on Admin { level } ...Fields}`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []Selection{
+				{Kind: FieldSelection, Name: "theme"},
+				{
+					Kind:  FieldSelection,
+					Alias: "locale",
+					Name:  "language",
+					Arguments: []Argument{
+						{Name: "format", Value: Value{Kind: VariableValue, Raw: "$format", Variable: "format"}},
+					},
+					SelectionSet: []Selection{{Kind: FieldSelection, Name: "code"}},
+				},
+				{
+					Kind:          InlineFragmentSelection,
+					TypeCondition: "Admin",
+					SelectionSet:  []Selection{{Kind: FieldSelection, Name: "level"}},
+				},
+				{Kind: FragmentSpreadSelection, Name: "Fields"},
+			}, actual)
+			assert.Equal(t, []string{"format"}, Selection{SelectionSet: actual}.Variables())
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		for _, c := range []struct {
+			tag string
+			err string
+		}{
+			{`theme`, `unexpected "theme" at offset 0`},
+			{`{}`, `unexpected "}" at offset 1`},
+			{`{theme`, `unexpected end of tag at offset 6`},
+			{`{theme} locale`, `unexpected "locale" at offset 8`},
+			{`{... on Admin}`, `unexpected "}" at offset 13`},
+			{`{...Fields {id}}`, `unexpected "{" at offset 11`},
+		} {
+			_, err := ParseSelectionSet(c.tag)
+			assert.EqualError(t, err, c.err, c.tag)
+		}
+	})
+}
+
+func Test_ParseFieldInfo(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		type Query struct {
+			Pic string `graphql:"smallPic: profilePic(size: 64)" include:"withPic"`
+		}
+		rt := reflect.TypeOf(Query{})
+		actual, err := ParseFieldInfo(rt, rt.Field(0))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "smallPic", actual.Alias())
+			assert.Equal(t, "profilePic", actual.Name())
+			assert.Equal(t, "smallPic", actual.FieldName())
+			assert.Len(t, actual.Arguments(), 1)
+			assert.Len(t, actual.Directives(), 1)
+			assert.Equal(t, []string{"withPic"}, actual.Variables())
+		}
+	})
+	t.Run("Case2", func(t *testing.T) {
+		type Query struct {
+			Name string `graphql:"name(id: $id"`
+		}
+		rt := reflect.TypeOf(Query{})
+		_, err := ParseFieldInfo(rt, rt.Field(0))
+		assert.EqualError(t, err, `invalid graphql tag "name(id: $id" of Go struct field mapping.Query.Name: `+
+			`unexpected end of tag at offset 12`)
+		assert.IsType(t, &TagError{}, err)
+	})
+	t.Run("Case3", func(t *testing.T) {
+		type Query struct {
+			Settings map[string]any `graphql:"settings" select:" {theme locale(format: $format)} "`
+		}
+		rt := reflect.TypeOf(Query{})
+		actual, err := ParseFieldInfo(rt, rt.Field(0))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []Selection{
+				{Kind: FieldSelection, Name: "theme"},
+				{
+					Kind: FieldSelection,
+					Name: "locale",
+					Arguments: []Argument{
+						{Name: "format", Value: Value{Kind: VariableValue, Raw: "$format", Variable: "format"}},
+					},
+				},
+			}, actual.SelectionSet())
+			assert.Equal(t, []string{"format"}, actual.Variables())
+		}
+	})
+	t.Run("Case4", func(t *testing.T) {
+		type Query struct {
+			Settings map[string]any `graphql:"... on Settings" select:"{theme}"`
+		}
+		rt := reflect.TypeOf(Query{})
+		_, err := ParseFieldInfo(rt, rt.Field(0))
+		assert.EqualError(t, err, `invalid select tag "{theme}" of Go struct field mapping.Query.Settings: `+
+			`select tag requires a graphql tag that is a field`)
+	})
+	t.Run("Case5", func(t *testing.T) {
+		type Query struct {
+			Node map[string]any `graphql:"node" select:"{id ... on User {...UserFields}}"`
+		}
+		rt := reflect.TypeOf(Query{})
+		_, err := ParseFieldInfo(rt, rt.Field(0))
+		assert.EqualError(t, err, `invalid select tag "{id ... on User {...UserFields}}" of Go struct field `+
+			`mapping.Query.Node: fragment spreads are not allowed in select tags`)
+	})
+}
+
+func Test_scanVariables(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual := scanVariables(`human(id: $id, name: "$notAVariable \" $x", bio: """ $y \""" $z """) @include(if: $with_Bio2) # $comment`)
+		assert.Equal(t, []string{"id", "with_Bio2"}, actual)
+	})
+	t.Run("Case2", func(t *testing.T) {
+		actual := scanVariables(`human(id: $ id, unit: $unit`)
+		assert.Equal(t, []string{"unit"}, actual)
+	})
+}
@@ -0,0 +1,101 @@
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Omittable is an input value that distinguishes between being absent, null and a value. This is useful for input objects
+// of update mutations, where an absent field is left unchanged and a field that is null is cleared. For example:
+//
+//	type UpdateUserInput struct {
+//		ID       graphql.ID
+//		Nickname graphql.Omittable[string]
+//	}
+//
+// The zero value is absent. When variables are encoded, absent variables are left out of the variables object, absent fields
+// of input objects and absent values of maps are left out of their JSON object, and absent elements of lists are encoded as
+// null. The GraphQL type of an Omittable[T] is the nullable GraphQL type of T.
+type Omittable[T any] struct {
+	value T
+	state omittableState
+}
+
+type omittableState uint8
+
+const (
+	omittableAbsent omittableState = iota
+	omittableNull
+	omittableValue
+)
+
+// OmittableValue returns an Omittable with value v.
+func OmittableValue[T any](v T) Omittable[T] {
+	return Omittable[T]{
+		value: v,
+		state: omittableValue,
+	}
+}
+
+// OmittableNull returns an Omittable that is null.
+func OmittableNull[T any]() Omittable[T] {
+	return Omittable[T]{
+		state: omittableNull,
+	}
+}
+
+// IsSet returns true if o is null or has a value, and false if o is absent.
+func (o Omittable[T]) IsSet() bool {
+	return o.state != omittableAbsent
+}
+
+// IsNull returns true if o is null.
+func (o Omittable[T]) IsNull() bool {
+	return o.state == omittableNull
+}
+
+// Get returns the value of o and true if o has a value, and the zero value and false otherwise.
+func (o Omittable[T]) Get() (T, bool) {
+	return o.value, o.state == omittableValue
+}
+
+// MarshalJSON implements the json.Marshaler interface. Since "encoding/json" cannot leave out values, absent values are
+// marshaled as null.
+func (o Omittable[T]) MarshalJSON() ([]byte, error) {
+	if o.state != omittableValue {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+func (Omittable[T]) omittableValueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (o Omittable[T]) omittableValue() (v any, set bool) {
+	if o.state != omittableValue {
+		return nil, o.state == omittableNull
+	}
+	return o.value, true
+}
+
+// omittable is implemented by all instantiations of Omittable.
+type omittable interface {
+	omittableValueType() reflect.Type
+	// omittableValue returns the value, which is nil if the Omittable is null, and whether the Omittable is set.
+	omittableValue() (v any, set bool)
+}
+
+var omittableType = reflect.TypeOf((*omittable)(nil)).Elem()
+
+// isAbsent returns true if rv is an Omittable that is absent.
+func isAbsent(rv reflect.Value) bool {
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || !rv.Type().Implements(omittableType) {
+		return false
+	}
+	_, set := rv.Interface().(omittable).omittableValue()
+	return !set
+}
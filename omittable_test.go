@@ -0,0 +1,66 @@
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUpdateUserInput struct {
+	ID       ID
+	Nickname Omittable[string]  `json:"nickname"`
+	Bio      Omittable[*string] `json:"bio"`
+	Age      Omittable[int]     `json:"age"`
+}
+
+func Test_Omittable(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		var o Omittable[string]
+		assert.False(t, o.IsSet())
+		assert.False(t, o.IsNull())
+		_, ok := o.Get()
+		assert.False(t, ok)
+		o = OmittableNull[string]()
+		assert.True(t, o.IsSet())
+		assert.True(t, o.IsNull())
+		o = OmittableValue("x")
+		assert.True(t, o.IsSet())
+		assert.False(t, o.IsNull())
+		v, ok := o.Get()
+		assert.True(t, ok)
+		assert.Equal(t, "x", v)
+	})
+	t.Run("Case2", func(t *testing.T) {
+		b, err := json.Marshal([]Omittable[int]{{}, OmittableNull[int](), OmittableValue(1)})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `[null,null,1]`, string(b))
+		}
+	})
+	t.Run("Case3", func(t *testing.T) {
+		actual, err := encodeVariables(mustNewVariables(t, map[string]any{
+			"input": testUpdateUserInput{
+				ID:       ID{"1"},
+				Nickname: OmittableValue("x"),
+				Bio:      OmittableNull[*string](),
+			},
+			"list": []Omittable[int]{{}, OmittableValue(2)},
+			"map":  map[string]Omittable[int]{"a": {}, "b": OmittableNull[int]()},
+			"omit": Omittable[int]{},
+		}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"input":{"id":"1","nickname":"x","bio":null},"list":[null,2],"map":{"b":null}}`, string(actual))
+		}
+	})
+	t.Run("Case4", func(t *testing.T) {
+		actual, err := graphQLType(reflect.TypeOf(Omittable[int]{}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Int", actual)
+		}
+		actual, err = variableType([]Omittable[ID]{}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "[ID]!", actual)
+		}
+	})
+}
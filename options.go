@@ -0,0 +1,100 @@
+package graphql
+
+import internalJSON "github.com/jbrekelmans/go-graphql/json"
+
+// Option configures a Client, or the construction of documents by ConstructQuery and ConstructMutation.
+// Options that are irrelevant to the function they are passed to are ignored.
+type Option func(*options)
+
+type options struct {
+	indent                    string
+	scalars                   *ScalarRegistry
+	requestCompression        ContentEncoding
+	requestCompressionMinSize int
+	possibleTypes             map[string][]string
+	interfaces                *InterfaceRegistry
+	ignoreUnknownProperties   bool
+	caseInsensitiveProperties bool
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.scalars == nil {
+		o.scalars = defaultScalars
+	}
+	return o
+}
+
+// WithRequestCompression compresses bodies of HTTP requests with the given content encoding, and sets the Content-Encoding
+// header of HTTP requests accordingly. Bodies smaller than minSize bytes are sent uncompressed, because compressing small
+// bodies typically increases their size.
+// Note that the server must support compressed requests.
+func WithRequestCompression(encoding ContentEncoding, minSize int) Option {
+	return func(o *options) {
+		o.requestCompression = encoding
+		o.requestCompressionMinSize = minSize
+	}
+}
+
+// WithPossibleTypes configures which object types implement each interface and are members of each union, as a map from
+// names of interfaces and unions to names of object types.
+//
+// Selection sets with inline fragments or fragment spreads on several types select __typename, so that the fields of inline
+// fragments and fragment spreads are only unmarshaled into the Go values of those that apply to the type of the response
+// object. A type condition applies if it is the __typename of the response object, or an interface or union whose possible
+// types (as per possibleTypes) include the __typename. Type conditions that are object types of possibleTypes other than the
+// __typename do not apply. Unknown type conditions (that do not occur in possibleTypes) may be interfaces or unions, so they
+// apply unless another inline fragment or fragment spread of the selection set has the __typename as type condition.
+// With this option, selection sets with a single type condition select __typename as well.
+func WithPossibleTypes(possibleTypes map[string][]string) Option {
+	return func(o *options) {
+		o.possibleTypes = possibleTypes
+	}
+}
+
+// WithIgnoreUnknownProperties configures a Client to ignore properties of objects in the data of responses that no Go struct
+// field is mapped to. By default, unmarshaling such responses returns an error that contains the JSON path of the first
+// unknown property. This is useful when decoding the results of Exec into partial structs, or when servers add properties
+// to responses.
+func WithIgnoreUnknownProperties() Option {
+	return func(o *options) {
+		o.ignoreUnknownProperties = true
+	}
+}
+
+// WithCaseInsensitiveProperties configures a Client to match the names of properties of objects in the data of responses to
+// the response keys of Go struct fields case-insensitively, which was the behavior of earlier versions of this package. By
+// default, names are matched exactly. Constructing documents fails if the response keys of fields of a selection set only
+// differ in case, so that the matching is never ambiguous.
+func WithCaseInsensitiveProperties() Option {
+	return func(o *options) {
+		o.caseInsensitiveProperties = true
+	}
+}
+
+// jsonOptions returns the options for unmarshaling responses.
+func (o *options) jsonOptions() []internalJSON.Option {
+	opts := []internalJSON.Option{
+		internalJSON.WithScalarTypes(o.scalars),
+		internalJSON.WithPossibleTypes(o.possibleTypes),
+		internalJSON.WithInterfaceTypes(o.interfaces),
+	}
+	if o.ignoreUnknownProperties {
+		opts = append(opts, internalJSON.WithIgnoreUnknownProperties())
+	}
+	if o.caseInsensitiveProperties {
+		opts = append(opts, internalJSON.WithCaseInsensitiveProperties())
+	}
+	return opts
+}
+
+// WithIndent pretty-prints documents, writing each selection on a separate line and indenting nested selection sets
+// with indent. An empty indent selects the compact form, which is the default.
+func WithIndent(indent string) Option {
+	return func(o *options) {
+		o.indent = indent
+	}
+}
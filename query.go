@@ -2,120 +2,815 @@ package graphql
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 
+	internalJSON "github.com/jbrekelmans/go-graphql/json"
 	"github.com/jbrekelmans/go-graphql/mapping"
 )
 
+// NamedFragment is implemented by Go struct types that define a named fragment. Such types are emitted once as a fragment
+// definition at the end of the document, and referenced by fragment spreads wherever they are used:
+//   - embedded fields (without graphql tag) of such types are spread instead of inlined; and
+//   - other fields of such types select the fragment, e.g. "author{...UserFields}".
+//
+// Alternatively, a field can spread a named fragment with a graphql tag like "...UserFields", in which case the type
+// condition is the name of the Go type of the field, unless the type implements NamedFragment.
+type NamedFragment = mapping.NamedFragment
+
+// ConstructQuery constructs the GraphQL document that (*Client).Query sends for q and variables, without sending it.
+// This is useful for reviewing documents, golden tests, allowlists of persisted operations and debugging.
+// The document is in compact form, unless WithIndent is passed to pretty-print it.
+// Returns an error if q does not define a valid query, for example if q defines an empty selection set.
+func ConstructQuery(q any, variables any, opts ...Option) (string, error) {
+	return constructOperation("query", q, variables, opts)
+}
+
+// ConstructMutation is like ConstructQuery, but constructs the document that (*Client).Mutate sends.
+func ConstructMutation(m any, variables any, opts ...Option) (string, error) {
+	return constructOperation("mutation", m, variables, opts)
+}
+
+func constructOperation(operationType string, q any, variables any, opts []Option) (string, error) {
+	vars, err := newVariables(variables)
+	if err != nil {
+		return "", err
+	}
+	o := newOptions(opts)
+	qb := newQueryBuilder(&o)
+	if err := qb.operation(operationType, q, vars); err != nil {
+		return "", err
+	}
+	return qb.String(), nil
+}
+
 type queryBuilder struct {
 	b         bytes.Buffer
 	commaFlag bool
+
+	// indent is the indentation per level of nested selection sets. The compact form is written if indent is empty.
+	indent string
+	depth  int
+
+	// scalars defines which Go types are selected as leaf fields.
+	scalars *ScalarRegistry
+	// possibleTypes is true if WithPossibleTypes is used, see structSelectionSet.
+	possibleTypes bool
+	// interfaces defines the implementations of Go interface types.
+	interfaces *InterfaceRegistry
+
+	// path is the path of Go struct fields of the selection being written, used in error messages.
+	path []string
+
+	// conditions are the usages of variables by @include and @skip directives defined by struct tags.
+	conditions []condition
+	// usages are all usages of variables by struct tags, including conditions.
+	usages []variableUsage
+
+	// types is the stack of struct types whose selection sets are being written, used to detect recursive types.
+	types []typeStackEntry
+	// boundedFields is the number of fields with a depth tag in path.
+	boundedFields int
+	// depths is the number of times each field with a depth tag occurs in path.
+	depths map[structField]int
+
+	// responseKeys is the stack of selection sets being written. Each element maps the lower case response keys of the
+	// fields of a selection set to the response keys and the paths of the Go struct fields that define them.
+	responseKeys []map[string]responseKey
+
+	// problems are the errors found so far that do not prevent writing the rest of the document, so that all of them can be
+	// reported at once.
+	problems []error
+
+	// fragments are the named fragments spread so far, by name.
+	fragments map[string]fragment
+	// fragmentQueue are the fragments whose definitions have not been written yet.
+	fragmentQueue []fragment
+	// definingFragment is the name of the fragment whose definition is being written, if any.
+	definingFragment string
+	// fragmentSpreads are the names of the fragments spread by the definition of each fragment, used to detect cycles.
+	fragmentSpreads map[string][]string
+}
+
+// condition is a usage of a variable by an @include or @skip directive.
+type condition struct {
+	directive string
+	variable  string
+	// path is the path of the Go struct field that defines the directive.
+	path string
+}
+
+// addConditions records the variables used by the @include and @skip directives defined by the struct tags of a field.
+func (qb *queryBuilder) addConditions(x mapping.FieldInfo) error {
+	for _, c := range [...]condition{{directive: "include", variable: x.IncludeIf()}, {directive: "skip", variable: x.SkipIf()}} {
+		if c.variable == "" {
+			continue
+		}
+		if !mapping.IsName(c.variable) {
+			return qb.errorf(`invalid variable name %#v in %s tag`, c.variable, c.directive)
+		}
+		c.path = strings.Join(qb.path, ".")
+		qb.conditions = append(qb.conditions, c)
+	}
+	return nil
+}
+
+// validateConditions returns an error if any variable used by an @include or @skip directive is not defined by variables,
+// or is not of type Boolean!.
+func validateConditions(conditions []condition, vars []variable, scalars *ScalarRegistry) error {
+	for _, c := range conditions {
+		v, ok := lookupVariable(vars, c.variable)
+		if !ok {
+			return fmt.Errorf(`variable $%s of @%s directive of Go struct field %s is not defined`, c.variable, c.directive,
+				c.path)
+		}
+		typeName, err := v.graphQLType(scalars)
+		if err != nil {
+			return fmt.Errorf(`variable $%s: %w`, c.variable, err)
+		}
+		if typeName != "Boolean!" {
+			return fmt.Errorf(`variable $%s of @%s directive of Go struct field %s must have type Boolean!, but has type %s`,
+				c.variable, c.directive, c.path, typeName)
+		}
+	}
+	return nil
+}
+
+// variableUsage is a usage of a variable by a struct tag.
+type variableUsage struct {
+	variable string
+	// path is the path of the Go struct field whose struct tag uses the variable.
+	path string
+}
+
+// addVariableUsages records the variables used by the struct tags of a field.
+func (qb *queryBuilder) addVariableUsages(x mapping.FieldInfo) {
+	for _, name := range x.Variables() {
+		qb.usages = append(qb.usages, variableUsage{
+			variable: name,
+			path:     strings.Join(qb.path, "."),
+		})
+	}
+}
+
+// validateVariableUsages returns an error listing the variables that are used by usages but not defined by vars, and the
+// variables that are defined by vars but not used by usages. The server rejects documents with such variables.
+// See https://spec.graphql.org/October2021/#sec-All-Variable-Uses-Defined and
+// https://spec.graphql.org/October2021/#sec-All-Variables-Used.
+func validateVariableUsages(usages []variableUsage, vars []variable) error {
+	var undefined []string
+	paths := map[string][]string{}
+	for _, u := range usages {
+		if _, ok := lookupVariable(vars, u.variable); ok {
+			continue
+		}
+		p := paths[u.variable]
+		if len(p) == 0 {
+			undefined = append(undefined, u.variable)
+		}
+		if len(p) == 0 || p[len(p)-1] != u.path {
+			paths[u.variable] = append(p, u.path)
+		}
+	}
+	var unused []string
+	for _, v := range vars {
+		used := false
+		for _, u := range usages {
+			if u.variable == v.name {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, "$"+v.name)
+		}
+	}
+	var problems []string
+	if len(undefined) > 0 {
+		for i, name := range undefined {
+			p := paths[name]
+			noun := "field"
+			if len(p) > 1 {
+				noun = "fields"
+			}
+			undefined[i] = fmt.Sprintf(`$%s (used by Go struct %s %s)`, name, noun, strings.Join(p, ", "))
+		}
+		problems = append(problems, "undefined variables: "+strings.Join(undefined, ", "))
+	}
+	if len(unused) > 0 {
+		problems = append(problems, "unused variables: "+strings.Join(unused, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf(`%s`, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateVariables validates the usages of vars by the struct tags of an operation.
+func validateVariables(conditions []condition, usages []variableUsage, vars []variable, scalars *ScalarRegistry) error {
+	if err := validateConditions(conditions, vars, scalars); err != nil {
+		return err
+	}
+	return validateVariableUsages(usages, vars)
 }
 
-func (qb *queryBuilder) operation(operationType string, q any, variables map[string]any) error {
+type typeStackEntry struct {
+	t reflect.Type
+	// boundedFields is the value of queryBuilder.boundedFields when the entry was pushed.
+	boundedFields int
+}
+
+// structField identifies a field of a Go struct type.
+type structField struct {
+	t     reflect.Type
+	index int
+}
+
+// pushType pushes struct type t onto qb.types. Returns an error if t is recursive, i.e. if t is already on the stack and
+// the recursion is not bounded by a field with a depth tag.
+func (qb *queryBuilder) pushType(t reflect.Type) error {
+	for _, e := range qb.types {
+		if e.t == t && e.boundedFields == qb.boundedFields {
+			return qb.errorf(`type %v is recursive (add a depth tag to a field to bound the recursion, e.g. depth:"3")`, t)
+		}
+	}
+	qb.types = append(qb.types, typeStackEntry{
+		t:             t,
+		boundedFields: qb.boundedFields,
+	})
+	return nil
+}
+
+func (qb *queryBuilder) popType() {
+	qb.types = qb.types[:len(qb.types)-1]
+}
+
+// fragment is a named fragment defined by a Go struct type.
+type fragment struct {
+	name          string
+	typeCondition string
+	t             reflect.Type
+}
+
+func newQueryBuilder(o *options) queryBuilder {
+	return queryBuilder{
+		indent:        o.indent,
+		scalars:       o.scalars,
+		interfaces:    o.interfaces,
+		possibleTypes: len(o.possibleTypes) > 0,
+	}
+}
+
+func (qb *queryBuilder) operation(operationType string, q any, vars []variable) error {
+	if err := qb.operationHeader(operationType, vars); err != nil {
+		return err
+	}
+	if err := qb.selectionSet(operationType, reflect.TypeOf(q)); err != nil {
+		return err
+	}
+	return validateVariables(qb.conditions, qb.usages, vars, qb.scalars)
+}
+
+// operationHeader writes everything of an operation that precedes its selection set.
+func (qb *queryBuilder) operationHeader(operationType string, vars []variable) error {
 	qb.raw(operationType)
-	qb.varDefs(variables)
-	n := qb.b.Len()
-	qb.selectionSetHelper(reflect.TypeOf(q), false)
-	if qb.b.Len() == n {
-		return fmt.Errorf(`invalid %s type %T`, operationType, q)
+	if err := qb.varDefs(vars); err != nil {
+		return err
+	}
+	if qb.indent != "" {
+		qb.b.WriteByte(' ')
 	}
 	return nil
 }
 
-func (qb *queryBuilder) selectionSetHelper(t reflect.Type, inline bool) (notEmpty bool) {
-	switch t.Kind() {
-	// NOTE: even if we add support for arrays here, unmarshaling JSON into Go arrays is not supported.
-	// TODO add support for unmarshaling into Go arrays.
-	case reflect.Ptr, reflect.Slice:
-		if qb.selectionSetHelper(t.Elem(), false) {
-			notEmpty = true
+// selectionSet writes the selection set of the operation defined by Go type t.
+func (qb *queryBuilder) selectionSet(operationType string, t reflect.Type) error {
+	n := qb.b.Len()
+	if t != nil {
+		if _, err := qb.selectionSetHelper(t, false); err != nil {
+			return err
 		}
+	}
+	if len(qb.problems) == 0 && qb.b.Len() == n {
+		return fmt.Errorf(`invalid %s type %v`, operationType, t)
+	}
+	if err := qb.fragmentDefinitions(); err != nil {
+		return err
+	}
+	return errors.Join(qb.problems...)
+}
+
+// problemf records an error at the selection identified by qb.path, see problems.
+func (qb *queryBuilder) problemf(format string, args ...any) {
+	qb.problems = append(qb.problems, qb.errorf(format, args...))
+}
+
+// responseKey is the response key of a field of a selection set.
+type responseKey struct {
+	key string
+	// path is the path of the Go struct field that defines the field.
+	path string
+}
+
+// addResponseKey records that the field identified by qb.path has response key key in the current selection set, and
+// records a problem if another field of the selection set has the same response key, or a response key that only differs
+// in case (which would be ambiguous when unmarshaling case-insensitively, see WithCaseInsensitiveProperties).
+func (qb *queryBuilder) addResponseKey(key string) {
+	keys := qb.responseKeys[len(qb.responseKeys)-1]
+	path := strings.Join(qb.path, ".")
+	lowerKey := strings.ToLower(key)
+	if other, ok := keys[lowerKey]; ok {
+		if other.key == key {
+			qb.problemf(`Go struct fields %s and %s both map to response key %#v`, other.path, path, key)
+		} else {
+			qb.problemf(`Go struct fields %s and %s map to response keys %#v and %#v, which only differ in case`, other.path,
+				path, other.key, key)
+		}
+		return
+	}
+	keys[lowerKey] = responseKey{
+		key:  key,
+		path: path,
+	}
+}
+
+func (qb *queryBuilder) selectionSetHelper(t reflect.Type, inline bool) (notEmpty bool, err error) {
+	if vt := conditionalValueTypeOf(t); vt != t {
+		return qb.selectionSetHelper(vt, inline)
+	}
+	if _, ok := qb.scalars.Lookup(t); ok {
+		// Custom scalars are leaf fields.
+		return false, nil
+	}
+	if impls := qb.interfaces.implementations(t); len(impls) > 0 {
+		return qb.interfaceSelectionSet(impls)
+	}
+	if internalJSON.IsUnmarshaler(t) {
+		// Go types that unmarshal JSON values as a whole are leaf fields.
+		return false, nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return qb.selectionSetHelper(t.Elem(), false)
+	case reflect.Map, reflect.Interface, reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128,
+		reflect.UnsafePointer:
+		qb.problemf(`unsupported Go type %v (register a custom scalar to select it as a leaf field)`, t)
 	case reflect.Struct:
 		if !inline {
-			qb.b.WriteByte('{')
-			qb.commaFlag = false
-			notEmpty = true
+			if name, typeCondition, ok := mapping.NamedFragmentOf(t); ok {
+				// Select the named fragment instead of its fields.
+				qb.openBrace()
+				qb.beginSelection()
+				if err := qb.fragmentSpread("..."+name, name, typeCondition, t); err != nil {
+					return false, err
+				}
+				qb.closeBrace()
+				return true, nil
+			}
+		}
+		return qb.structSelectionSet(t, inline)
+	}
+	return
+}
+
+// structSelectionSet writes the selections defined by the fields of struct type t. The selections are enclosed in braces
+// unless inline is true.
+func (qb *queryBuilder) structSelectionSet(t reflect.Type, inline bool) (notEmpty bool, err error) {
+	if err := qb.pushType(t); err != nil {
+		return false, err
+	}
+	defer qb.popType()
+	if !inline {
+		qb.openBrace()
+		notEmpty = true
+		qb.responseKeys = append(qb.responseKeys, map[string]responseKey{})
+		defer func() {
+			qb.responseKeys = qb.responseKeys[:len(qb.responseKeys)-1]
+		}()
+		typeConditions := map[string]struct{}{}
+		selectsTypename := scanTypeConditions(t, typeConditions, nil)
+		if !selectsTypename && (len(typeConditions) > 1 || (len(typeConditions) == 1 && qb.possibleTypes)) {
+			// Select __typename first, so that fields of inline fragments and fragment spreads are only unmarshaled into
+			// those that apply to the type of the response object. Without possible types, a single type condition
+			// applies to every response object, see WithPossibleTypes.
+			qb.beginSelection()
+			qb.raw("__typename")
+			qb.commaFlag = true
+		}
+	}
+	hasSelections := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		qb.path = append(qb.path, f.Name)
+		x, err := mapping.ParseFieldInfo(t, f)
+		if err != nil {
+			return false, qb.errorf(`%w`, err)
 		}
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			if !f.IsExported() {
+		key := structField{t: t, index: i}
+		if depth := x.Depth(); depth > 0 {
+			if qb.depths[key] >= depth {
+				// The field is not selected, so the recursion ends here.
+				qb.path = qb.path[:len(qb.path)-1]
 				continue
 			}
-			x := mapping.NewFieldInfo(t.Field(i))
-			notEmpty = true
-			if !x.Inline() {
-				if qb.commaFlag {
-					qb.b.WriteByte(',')
-					qb.commaFlag = false
+			if qb.depths == nil {
+				qb.depths = map[structField]int{}
+			}
+			qb.depths[key]++
+			qb.boundedFields++
+		}
+		if err := qb.addConditions(x); err != nil {
+			return false, err
+		}
+		qb.addVariableUsages(x)
+		switch {
+		case x.IsFragmentSpread():
+			name, typeCondition, ok := mapping.NamedFragmentOf(f.Type)
+			if !ok {
+				// Derive the type condition from the name of the Go type.
+				name, typeCondition = x.FragmentName(), mapping.UnwrapPointerType(f.Type).Name()
+			} else if name != x.FragmentName() {
+				return false, qb.errorf(`graphql tag spreads fragment %s but type %v defines fragment %s`,
+					x.FragmentName(), f.Type, name)
+			}
+			qb.beginSelection()
+			if err := qb.fragmentSpread(x.GraphQL(), name, typeCondition, f.Type); err != nil {
+				return false, err
+			}
+			hasSelections = true
+		case !x.Inline():
+			if strings.TrimSpace(x.GraphQL()) == "" {
+				return false, qb.errorf(`graphql tag is empty`)
+			}
+			if x.IsInlineFragment() {
+				if ft := mapping.UnwrapPointerType(conditionalValueTypeOf(f.Type)); ft.Kind() != reflect.Struct {
+					qb.problemf(`inline fragment has non-struct type %v`, f.Type)
+				}
+			} else {
+				qb.addResponseKey(x.FieldName())
+			}
+			qb.beginSelection()
+			qb.raw(x.GraphQL())
+			if selectionSet := x.SelectionSet(); selectionSet != nil {
+				// The select tag replaces the selection set defined by the Go type of the field.
+				qb.selections(selectionSet)
+			} else {
+				isNotEmpty, err := qb.selectionSetHelper(f.Type, false)
+				if err != nil {
+					return false, err
 				}
-				qb.raw(x.GraphQL())
-				if isEmpty := !qb.selectionSetHelper(f.Type, false); isEmpty {
+				if !isNotEmpty {
 					qb.commaFlag = true
 				}
-			} else {
-				qb.selectionSetHelper(f.Type, true)
 			}
+			hasSelections = true
+		default:
+			if ft := mapping.UnwrapPointerType(f.Type); ft.Kind() != reflect.Struct {
+				qb.problemf(`embedded field of non-struct type %v must have a graphql tag`, f.Type)
+			}
+			inlineNotEmpty, err := qb.selectionSetHelper(f.Type, true)
+			if err != nil {
+				return false, err
+			}
+			hasSelections = hasSelections || inlineNotEmpty
 		}
-		if !inline {
-			qb.b.WriteByte('}')
-			qb.commaFlag = false
+		if x.Depth() > 0 {
+			qb.depths[key]--
+			qb.boundedFields--
 		}
+		qb.path = qb.path[:len(qb.path)-1]
 	}
+	if inline {
+		return hasSelections, nil
+	}
+	if !hasSelections {
+		// An empty selection set is a syntax error.
+		return false, qb.errorf(`type %v defines an empty selection set`, t)
+	}
+	qb.closeBrace()
 	return
 }
 
-func (qb *queryBuilder) raw(s string) {
-	qb.b.WriteString(s)
-}
-
-func (qb *queryBuilder) String() string {
-	return qb.b.String()
+// selections writes a selection set parsed from a select tag (see mapping.ParseSelectionSet).
+func (qb *queryBuilder) selections(set []mapping.Selection) {
+	qb.openBrace()
+	for _, sel := range set {
+		qb.beginSelection()
+		if sel.Kind == mapping.InlineFragmentSelection {
+			qb.raw("...")
+			if sel.TypeCondition != "" {
+				qb.raw(" on ")
+				qb.raw(sel.TypeCondition)
+			}
+		} else {
+			if sel.Alias != "" {
+				qb.raw(sel.Alias)
+				qb.b.WriteByte(':')
+				if qb.indent != "" {
+					qb.b.WriteByte(' ')
+				}
+			}
+			qb.raw(sel.Name)
+			qb.arguments(sel.Arguments)
+		}
+		for _, d := range sel.Directives {
+			qb.raw(" @")
+			qb.raw(d.Name)
+			qb.arguments(d.Arguments)
+		}
+		if sel.SelectionSet != nil {
+			qb.selections(sel.SelectionSet)
+		} else {
+			qb.commaFlag = true
+		}
+	}
+	qb.closeBrace()
 }
 
-func (qb *queryBuilder) Type(t reflect.Type) {
-	nonNull := true
-	for t.Kind() == reflect.Ptr {
-		t = t.Elem()
-		nonNull = false
-	}
-	switch t.Kind() {
-	case reflect.Slice, reflect.Array:
-		qb.b.WriteByte('[')
-		qb.Type(t.Elem())
-		qb.b.WriteByte(']')
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		qb.raw("Int")
-	case reflect.String:
-		qb.raw("String")
-	case reflect.Float32, reflect.Float64:
-		qb.raw("Float")
-	case reflect.Bool:
-		qb.raw("Boolean")
-	default:
-		name := t.Name()
-		qb.raw(name)
-	}
-	if nonNull {
-		qb.b.WriteByte('!')
-	}
-}
-
-func (qb *queryBuilder) varDefs(variables map[string]any) {
-	// https://spec.graphql.org/October2021/#VariableDefinitions
-	n := len(variables)
-	if n == 0 {
+// arguments writes the arguments of a field or directive parsed from a select tag.
+func (qb *queryBuilder) arguments(args []mapping.Argument) {
+	if len(args) == 0 {
 		return
 	}
 	qb.b.WriteByte('(')
-	for varName, v := range variables {
-		qb.b.WriteByte('$')
-		qb.raw(varName)
+	for i, a := range args {
+		if i > 0 {
+			qb.b.WriteByte(',')
+			if qb.indent != "" {
+				qb.b.WriteByte(' ')
+			}
+		}
+		qb.raw(a.Name)
 		qb.b.WriteByte(':')
-		t := reflect.TypeOf(v)
-		qb.Type(t)
+		if qb.indent != "" {
+			qb.b.WriteByte(' ')
+		}
+		qb.raw(a.Value.Raw)
 	}
 	qb.b.WriteByte(')')
 }
+
+// interfaceSelectionSet writes the selection set of a Go interface type, which selects __typename and an inline fragment
+// for each implementation.
+func (qb *queryBuilder) interfaceSelectionSet(impls []implementation) (notEmpty bool, err error) {
+	qb.openBrace()
+	qb.beginSelection()
+	qb.raw("__typename")
+	qb.commaFlag = true
+	for _, impl := range impls {
+		qb.path = append(qb.path, "("+impl.t.String()+")")
+		qb.beginSelection()
+		qb.raw("... on ")
+		qb.raw(impl.typename)
+		if t := mapping.UnwrapPointerType(impl.t); t.Kind() != reflect.Struct {
+			qb.problemf(`implementation has non-struct type %v`, impl.t)
+		} else if _, err := qb.selectionSetHelper(impl.t, false); err != nil {
+			return false, err
+		}
+		qb.path = qb.path[:len(qb.path)-1]
+	}
+	qb.closeBrace()
+	return true, nil
+}
+
+// scanTypeConditions adds the type conditions of the inline fragments and fragment spreads of the selection set defined by
+// struct type t to typeConditions, including those nested in other inline fragments, fragment spreads and embedded structs
+// that are inlined. Returns whether the selection set selects __typename explicitly. seen are the struct types being
+// scanned, to avoid infinite recursion.
+func scanTypeConditions(t reflect.Type, typeConditions map[string]struct{}, seen []reflect.Type) (selectsTypename bool) {
+	for _, s := range seen {
+		if s == t {
+			return false
+		}
+	}
+	seen = append(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		x := mapping.NewFieldInfo(f)
+		var typeCondition string
+		switch {
+		case x.IsInlineFragment():
+			typeCondition = x.TypeCondition()
+		case x.IsFragmentSpread():
+			_, typeCondition, _ = mapping.NamedFragmentOf(f.Type)
+		case x.Inline():
+		default:
+			if x.FieldName() == "__typename" {
+				selectsTypename = true
+			}
+			continue
+		}
+		if typeCondition != "" {
+			typeConditions[typeCondition] = struct{}{}
+		}
+		if ft := mapping.UnwrapPointerType(f.Type); ft.Kind() == reflect.Struct {
+			if scanTypeConditions(ft, typeConditions, seen) && x.Inline() {
+				selectsTypename = true
+			}
+		}
+	}
+	return selectsTypename
+}
+
+// fragmentSpread writes graphQL, which spreads the named fragment defined by Go type t (e.g. "...UserFields" or
+// "...UserFields @skip(if: $x)"), and records the fragment so its definition is written by fragmentDefinitions.
+func (qb *queryBuilder) fragmentSpread(graphQL, name, typeCondition string, t reflect.Type) error {
+	t = mapping.UnwrapPointerType(t)
+	if t.Kind() != reflect.Struct {
+		return qb.errorf(`fragment %s has non-struct type %v`, name, t)
+	}
+	if !mapping.IsName(name) {
+		return qb.errorf(`invalid fragment name %#v of type %v`, name, t)
+	}
+	if !mapping.IsName(typeCondition) {
+		return qb.errorf(`invalid type condition %#v of fragment %s of type %v (implement graphql.NamedFragment to `+
+			`define the type condition)`, typeCondition, name, t)
+	}
+	if qb.definingFragment != "" {
+		if qb.fragmentSpreads == nil {
+			qb.fragmentSpreads = map[string][]string{}
+		}
+		qb.fragmentSpreads[qb.definingFragment] = append(qb.fragmentSpreads[qb.definingFragment], name)
+	}
+	qb.raw(strings.TrimSpace(graphQL))
+	qb.commaFlag = true
+	if other, ok := qb.fragments[name]; ok {
+		if other.t != t {
+			return qb.errorf(`fragment %s is defined by both type %v and type %v`, name, other.t, t)
+		}
+		return nil
+	}
+	if qb.fragments == nil {
+		qb.fragments = map[string]fragment{}
+	}
+	f := fragment{
+		name:          name,
+		typeCondition: typeCondition,
+		t:             t,
+	}
+	qb.fragments[name] = f
+	qb.fragmentQueue = append(qb.fragmentQueue, f)
+	return nil
+}
+
+// fragmentDefinitions writes the definitions of all fragments spread so far, including fragments spread by these
+// definitions. Returns an error if fragments spread themselves, which the GraphQL specification forbids.
+func (qb *queryBuilder) fragmentDefinitions() error {
+	var names []string
+	for len(qb.fragmentQueue) > 0 {
+		f := qb.fragmentQueue[0]
+		qb.fragmentQueue = qb.fragmentQueue[1:]
+		names = append(names, f.name)
+		if qb.indent != "" {
+			qb.raw("\n\n")
+		}
+		qb.raw("fragment ")
+		qb.raw(f.name)
+		qb.raw(" on ")
+		qb.raw(f.typeCondition)
+		if qb.indent != "" {
+			qb.b.WriteByte(' ')
+		}
+		qb.path = append(qb.path[:0], f.t.String())
+		qb.definingFragment = f.name
+		if _, err := qb.structSelectionSet(f.t, false); err != nil {
+			return err
+		}
+	}
+	qb.definingFragment = ""
+	qb.path = qb.path[:0]
+	visited := map[string]bool{}
+	for _, name := range names {
+		if cycle := qb.fragmentCycle(name, visited, nil); cycle != nil {
+			return qb.errorf(`fragment %s of type %v spreads itself (%s)`, cycle[0], qb.fragments[cycle[0]].t,
+				strings.Join(cycle, " -> "))
+		}
+	}
+	return nil
+}
+
+// fragmentCycle returns the names of fragments that form a cycle reachable from the fragment named name, starting and ending
+// with the same name, or nil if there is none. visited are the names of the fragments that have been checked completely, and
+// stack are the names of the fragments being checked.
+func (qb *queryBuilder) fragmentCycle(name string, visited map[string]bool, stack []string) []string {
+	for i, other := range stack {
+		if other == name {
+			return append(stack[i:len(stack):len(stack)], name)
+		}
+	}
+	if visited[name] {
+		return nil
+	}
+	stack = append(stack, name)
+	for _, spread := range qb.fragmentSpreads[name] {
+		if cycle := qb.fragmentCycle(spread, visited, stack); cycle != nil {
+			return cycle
+		}
+	}
+	visited[name] = true
+	return nil
+}
+
+// errorf formats an error that occurred while writing the selection identified by qb.path.
+func (qb *queryBuilder) errorf(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	var tagErr *mapping.TagError
+	if len(qb.path) == 0 || errors.As(err, &tagErr) {
+		// A *mapping.TagError already names the Go struct field.
+		return err
+	}
+	return fmt.Errorf(`error at Go struct field %s: %w`, strings.Join(qb.path, "."), err)
+}
+
+func (qb *queryBuilder) openBrace() {
+	if qb.indent != "" && qb.depth > 0 {
+		qb.b.WriteByte(' ')
+	}
+	qb.b.WriteByte('{')
+	qb.depth++
+	qb.commaFlag = false
+}
+
+// beginSelection separates the selection about to be written from any previous selection in the same selection set.
+func (qb *queryBuilder) beginSelection() {
+	if qb.indent != "" {
+		qb.newline()
+	} else if qb.commaFlag {
+		qb.b.WriteByte(',')
+	}
+	qb.commaFlag = false
+}
+
+func (qb *queryBuilder) closeBrace() {
+	qb.depth--
+	if qb.indent != "" {
+		qb.newline()
+	}
+	qb.b.WriteByte('}')
+	qb.commaFlag = false
+}
+
+func (qb *queryBuilder) newline() {
+	qb.b.WriteByte('\n')
+	for i := 0; i < qb.depth; i++ {
+		qb.raw(qb.indent)
+	}
+}
+
+// selectionSetCache caches the selection sets of operations by Go type, because constructing them using reflection is
+// relatively expensive and operations are typically executed many times.
+// A selectionSetCache must only be used with a single *options.
+type selectionSetCache struct {
+	m sync.Map
+}
+
+// cachedSelectionSet is a selection set (followed by fragment definitions) and its usages of variables.
+type cachedSelectionSet struct {
+	text       string
+	conditions []condition
+	usages     []variableUsage
+}
+
+// operation is like (*queryBuilder).operation, but only constructs the selection set if it is not cached.
+func (c *selectionSetCache) operation(o *options, operationType string, q any, vars []variable) (string, error) {
+	t := reflect.TypeOf(q)
+	v, ok := c.m.Load(t)
+	if !ok {
+		qb := newQueryBuilder(o)
+		if err := qb.selectionSet(operationType, t); err != nil {
+			return "", err
+		}
+		v, _ = c.m.LoadOrStore(t, &cachedSelectionSet{
+			text:       qb.String(),
+			conditions: qb.conditions,
+			usages:     qb.usages,
+		})
+	}
+	selectionSet := v.(*cachedSelectionSet)
+	if err := validateVariables(selectionSet.conditions, selectionSet.usages, vars, o.scalars); err != nil {
+		return "", err
+	}
+	qb := newQueryBuilder(o)
+	if err := qb.operationHeader(operationType, vars); err != nil {
+		return "", err
+	}
+	qb.raw(selectionSet.text)
+	return qb.String(), nil
+}
+
+func (qb *queryBuilder) raw(s string) {
+	qb.b.WriteString(s)
+}
+
+func (qb *queryBuilder) String() string {
+	return qb.b.String()
+}
@@ -1,6 +1,10 @@
 package graphql
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"reflect"
 	"testing"
 
@@ -45,6 +49,62 @@ func Test_queryBuilder(t *testing.T) {
 			qb.selectionSetHelper(reflect.TypeOf(Query{}), false)
 			assert.Equal(t, "{id}", qb.String())
 		})
+		t.Run("Case4", func(t *testing.T) {
+			type Point struct {
+				Coordinates [2]float64
+			}
+			type Query struct {
+				Triangle [3]*Point
+			}
+			var qb queryBuilder
+			qb.selectionSetHelper(reflect.TypeOf(Query{}), false)
+			assert.Equal(t, "{triangle{coordinates}}", qb.String())
+		})
+		t.Run("Case5", func(t *testing.T) {
+			type Query struct {
+				Node struct {
+					ID       ID
+					Geometry json.RawMessage
+					Bounds   *testGeoJSON
+					Color    testColor
+				}
+			}
+			var qb queryBuilder
+			qb.selectionSetHelper(reflect.TypeOf(Query{}), false)
+			assert.Equal(t, "{node{id,geometry,bounds,color}}", qb.String())
+		})
+	})
+	t.Run("selectionSetHelper errors", func(t *testing.T) {
+		t.Run("EmptySelectionSet", func(t *testing.T) {
+			type Query struct {
+				Viewer struct {
+					Settings struct{}
+				}
+			}
+			var qb queryBuilder
+			_, err := qb.selectionSetHelper(reflect.TypeOf(Query{}), false)
+			assert.EqualError(t, err, `error at Go struct field Viewer.Settings: type struct {} defines an empty selection set`)
+		})
+		t.Run("EmptyTag", func(t *testing.T) {
+			type Query struct {
+				Name string `graphql:" "`
+			}
+			var qb queryBuilder
+			_, err := qb.selectionSetHelper(reflect.TypeOf(Query{}), false)
+			assert.EqualError(t, err, `error at Go struct field Name: graphql tag is empty`)
+		})
+		t.Run("InvalidTag", func(t *testing.T) {
+			type Human struct {
+				Name string `graphql:"name(unit: METER"`
+			}
+			type Query struct {
+				Human Human
+			}
+			var qb queryBuilder
+			_, err := qb.selectionSetHelper(reflect.TypeOf(Query{}), false)
+			assert.EqualError(t, err, `invalid graphql tag "name(unit: METER" of Go struct field graphql.Human.Name: `+
+				`unexpected end of tag at offset 16`)
+		})
 	})
 	t.Run("operation", func(t *testing.T) {
 		t.Run("Case1", func(t *testing.T) {
@@ -57,13 +117,646 @@ func Test_queryBuilder(t *testing.T) {
 			)
 		})
 	})
+	t.Run("selectionSetCache", func(t *testing.T) {
+		t.Run("Case1", func(t *testing.T) {
+			type Query struct {
+				Name string `graphql:"name(id:$id)"`
+			}
+			var c selectionSetCache
+			for i := 0; i < 2; i++ {
+				operation, err := c.operation(&options{}, "query", &Query{}, mustNewVariables(t, map[string]any{"id": ID{"1"}}))
+				if assert.NoError(t, err) {
+					assert.Equal(t, "query($id:ID!){name(id:$id)}", operation)
+				}
+			}
+			selectionSet, ok := c.m.Load(reflect.TypeOf(&Query{}))
+			if assert.True(t, ok) {
+				assert.Equal(t, "{name(id:$id)}", selectionSet.(*cachedSelectionSet).text)
+			}
+		})
+		t.Run("Case2", func(t *testing.T) {
+			var c selectionSetCache
+			_, err := c.operation(&options{}, "mutation", nil, nil)
+			assert.EqualError(t, err, "invalid mutation type <nil>")
+		})
+	})
 	t.Run("varDefs", func(t *testing.T) {
 		t.Run("Case1", func(t *testing.T) {
 			var qb queryBuilder
-			qb.varDefs(map[string]any{
+			qb.varDefs(mustNewVariables(t, map[string]any{
 				"id": ID{"123"},
-			})
+			}))
 			assert.Equal(t, "($id:ID!)", qb.String())
 		})
+		t.Run("Case2", func(t *testing.T) {
+			var qb queryBuilder
+			qb.varDefs(mustNewVariables(t, map[string]any{
+				"b": "x",
+				"a": 1,
+				"c": (*bool)(nil),
+			}))
+			assert.Equal(t, "($a:Int!,$b:String!,$c:Boolean)", qb.String())
+		})
+		t.Run("Case3", func(t *testing.T) {
+			var qb queryBuilder
+			qb.varDefs(mustNewVariables(t, struct {
+				B string
+				A int `graphql:"first:Int = 20"`
+			}{}))
+			assert.Equal(t, "($b:String!,$first:Int=20)", qb.String())
+		})
+		t.Run("Case4", func(t *testing.T) {
+			var qb queryBuilder
+			qb.varDefs(mustNewVariables(t, struct {
+				First Var[int]
+				Unit  Var[testLengthUnit]   `graphql:"unit:LengthUnit"`
+				Units Var[[]testLengthUnit] `graphql:"units"`
+				After Var[*string]
+			}{
+				First: Var[int]{Type: "Int", Default: OmittableValue(20)},
+				Unit:  Var[testLengthUnit]{Default: OmittableValue[testLengthUnit]("METER")},
+				Units: Var[[]testLengthUnit]{Type: "[LengthUnit!]", Default: OmittableValue([]testLengthUnit{"METER", "FOOT"})},
+				After: Var[*string]{Default: OmittableNull[*string]()},
+			}))
+			assert.Equal(t, "($first:Int=20,$unit:LengthUnit=METER,$units:[LengthUnit!]=[METER,FOOT],$after:String=null)",
+				qb.String())
+		})
+	})
+}
+
+type UserFieldsFragment struct {
+	Name   string
+	Avatar AvatarFieldsFragment
+}
+
+func (UserFieldsFragment) GraphQLFragment() (name, typeCondition string) {
+	return "UserFields", "User"
+}
+
+type AvatarFieldsFragment struct {
+	URL string
+}
+
+func (*AvatarFieldsFragment) GraphQLFragment() (name, typeCondition string) {
+	return "AvatarFields", "Avatar"
+}
+
+type Repository struct {
+	Name string
+}
+
+type Organization struct {
+	Login string
+}
+
+func Test_queryBuilder_fragments(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		var q struct {
+			Viewer struct {
+				ID string
+				UserFieldsFragment
+			}
+			Users []*UserFieldsFragment
+			Repo  struct {
+				Repository `graphql:"...RepoFields"`
+			}
+		}
+		actual, err := ConstructQuery(&q, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query{viewer{id,...UserFields}users{...UserFields}repo{...RepoFields}}`+
+				`fragment UserFields on User{name,avatar{...AvatarFields}}`+
+				`fragment RepoFields on Repository{name}`+
+				`fragment AvatarFields on Avatar{url}`, actual)
+		}
+	})
+	t.Run("Pretty", func(t *testing.T) {
+		var q struct {
+			Viewer UserFieldsFragment
+		}
+		actual, err := ConstructQuery(&q, nil, WithIndent("  "))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query {
+  viewer {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  name
+  avatar {
+    ...AvatarFields
+  }
+}
+
+fragment AvatarFields on Avatar {
+  url
+}`, actual)
+		}
+	})
+	t.Run("Directives", func(t *testing.T) {
+		var q struct {
+			Viewer struct {
+				ID   string
+				User UserFieldsFragment `graphql:"...UserFields @skip(if: $y)"`
+			}
+		}
+		actual, err := ConstructQuery(&q, map[string]any{"y": false})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($y:Boolean!){viewer{id,...UserFields @skip(if: $y)}}`+
+				`fragment UserFields on User{name,avatar{...AvatarFields}}`+
+				`fragment AvatarFields on Avatar{url}`, actual)
+		}
+	})
+	t.Run("ConflictingDefinitions", func(t *testing.T) {
+		var q struct {
+			A Repository   `graphql:"...Fields"`
+			B Organization `graphql:"...Fields"`
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, `error at Go struct field B: fragment Fields is defined by both type graphql.Repository `+
+			`and type graphql.Organization`)
+	})
+	t.Run("UnnamedType", func(t *testing.T) {
+		var q struct {
+			A struct {
+				Name string
+			} `graphql:"...Fields"`
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.ErrorContains(t, err, `error at Go struct field A: invalid type condition "" of fragment Fields`)
+	})
+	t.Run("MismatchingName", func(t *testing.T) {
+		var q struct {
+			Viewer UserFieldsFragment `graphql:"...ViewerFields"`
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, `error at Go struct field Viewer: graphql tag spreads fragment ViewerFields but type `+
+			`graphql.UserFieldsFragment defines fragment UserFields`)
+	})
+}
+
+func Test_ConstructQuery(t *testing.T) {
+	type Query struct {
+		Hero struct {
+			Name  string
+			Droid struct {
+				PrimaryFunction string
+			} `graphql:"... on Droid"`
+		} `graphql:"hero(episode: $ep)"`
+		Count int
+	}
+	variables := map[string]any{
+		"ep": "JEDI",
+	}
+	t.Run("Compact", func(t *testing.T) {
+		actual, err := ConstructQuery(&Query{}, variables)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($ep:String!){hero(episode: $ep){name,... on Droid{primaryFunction}}count}`, actual)
+		}
+	})
+	t.Run("Pretty", func(t *testing.T) {
+		actual, err := ConstructQuery(&Query{}, variables, WithIndent("  "))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query ($ep: String!) {
+  hero(episode: $ep) {
+    name
+    ... on Droid {
+      primaryFunction
+    }
+  }
+  count
+}`, actual)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ConstructQuery(struct{}{}, nil)
+		assert.EqualError(t, err, `type struct {} defines an empty selection set`)
+	})
+}
+
+func Test_ConstructMutation(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		var m struct {
+			Delete bool `graphql:"delete(id: $id)"`
+		}
+		actual, err := ConstructMutation(&m, map[string]any{"id": ID{"1"}}, WithIndent("\t"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "mutation ($id: ID!) {\n\tdelete(id: $id)\n}", actual)
+		}
+	})
+}
+
+func Test_validateVariableUsages(t *testing.T) {
+	type Query struct {
+		Human struct {
+			Name   string
+			Height float64 `graphql:"height(unit: $unit)"`
+			Mass   float64 `graphql:"mass(unit: $unit)"`
+		} `graphql:"human(id: $id)"`
+	}
+	t.Run("Case1", func(t *testing.T) {
+		_, err := ConstructQuery(&Query{}, map[string]any{"id": ID{"1"}, "first": 1, "after": "x"})
+		assert.EqualError(t, err, `undefined variables: $unit (used by Go struct fields Human.Height, Human.Mass); `+
+			`unused variables: $after, $first`)
+	})
+	t.Run("Case2", func(t *testing.T) {
+		_, err := ConstructQuery(&Query{}, map[string]any{"unit": "METER"})
+		assert.EqualError(t, err, `undefined variables: $id (used by Go struct field Human)`)
+	})
+	t.Run("Case3", func(t *testing.T) {
+		var c selectionSetCache
+		for i := 0; i < 2; i++ {
+			_, err := c.operation(&options{}, "query", &Query{}, mustNewVariables(t, map[string]any{"id": ID{"1"}}))
+			assert.EqualError(t, err, `undefined variables: $unit (used by Go struct fields Human.Height, Human.Mass)`)
+		}
+	})
+}
+
+type testComment struct {
+	Text    string
+	Replies []testComment `depth:"2"`
+}
+
+type testUnboundedComment struct {
+	Text   string
+	Parent *testUnboundedComment
+}
+
+type testPerson struct {
+	Name    string
+	Friends []testFriend
+}
+
+type testFriend struct {
+	Since  string
+	Person testPerson
+}
+
+type testCommentFragment struct {
+	Body    string
+	Replies []testCommentFragment
+}
+
+func (testCommentFragment) GraphQLFragment() (name, typeCondition string) {
+	return "CommentFields", "Comment"
+}
+
+type testUserFragment struct {
+	Login string
+	Repos []testRepoFragment
+}
+
+func (testUserFragment) GraphQLFragment() (name, typeCondition string) {
+	return "UserFields", "User"
+}
+
+type testRepoFragment struct {
+	Name  string
+	Owner testUserFragment
+}
+
+func (testRepoFragment) GraphQLFragment() (name, typeCondition string) {
+	return "RepoFields", "Repository"
+}
+
+func Test_queryBuilder_recursion(t *testing.T) {
+	t.Run("Depth", func(t *testing.T) {
+		var q struct {
+			Comments []testComment
+		}
+		actual, err := ConstructQuery(&q, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "query{comments{text,replies{text,replies{text}}}}", actual)
+		}
+	})
+	t.Run("Recursive", func(t *testing.T) {
+		var q struct {
+			Comment testUnboundedComment
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, `error at Go struct field Comment.Parent: type graphql.testUnboundedComment is recursive `+
+			`(add a depth tag to a field to bound the recursion, e.g. depth:"3")`)
+	})
+	t.Run("MutuallyRecursive", func(t *testing.T) {
+		var q struct {
+			Viewer testPerson
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, `error at Go struct field Viewer.Friends.Person: type graphql.testPerson is recursive `+
+			`(add a depth tag to a field to bound the recursion, e.g. depth:"3")`)
+	})
+	t.Run("RecursiveFragment", func(t *testing.T) {
+		var q struct {
+			Comment testCommentFragment
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, `fragment CommentFields of type graphql.testCommentFragment spreads itself `+
+			`(CommentFields -> CommentFields)`)
+	})
+	t.Run("MutuallyRecursiveFragments", func(t *testing.T) {
+		var q struct {
+			Viewer testUserFragment
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, `fragment UserFields of type graphql.testUserFragment spreads itself `+
+			`(UserFields -> RepoFields -> UserFields)`)
+	})
+	t.Run("InvalidDepth", func(t *testing.T) {
+		var q struct {
+			Comment struct {
+				Text string `depth:"0"`
+			}
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.ErrorContains(t, err, `invalid depth tag "0" of Go struct field struct { Text string "depth:\"0\"" }.Text: `+
+			`depth must be positive`)
+	})
+	t.Run("Unmarshal", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"comments":[{"text":"a","replies":[{"text":"b","replies":[{"text":"c"}]}]}]}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport})
+		var q struct {
+			Comments []testComment
+		}
+		_, err := c.Query(context.Background(), &q, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []testComment{{Text: "a", Replies: []testComment{{Text: "b", Replies: []testComment{{Text: "c"}}}}}},
+				q.Comments)
+		}
+	})
+}
+
+func Test_queryBuilder_validation(t *testing.T) {
+	t.Run("UnsupportedKinds", func(t *testing.T) {
+		var q struct {
+			Viewer struct {
+				Login    string
+				Settings map[string]int
+				Avatar   any
+				Callback func()
+			}
+			Meta json.RawMessage
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, "error at Go struct field Viewer.Settings: unsupported Go type map[string]int (register a "+
+			"custom scalar to select it as a leaf field)\n"+
+			"error at Go struct field Viewer.Avatar: unsupported Go type interface {} (register a custom scalar to select it "+
+			"as a leaf field)\n"+
+			"error at Go struct field Viewer.Callback: unsupported Go type func() (register a custom scalar to select it as "+
+			"a leaf field)")
+	})
+	t.Run("InlineFragment", func(t *testing.T) {
+		type Name string
+		var q struct {
+			Hero struct {
+				Droid *struct {
+					PrimaryFunction string
+				} `graphql:"... on Droid"`
+				Human Name `graphql:"... on Human"`
+				Name
+			}
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, "error at Go struct field Hero.Human: inline fragment has non-struct type graphql.Name\n"+
+			"error at Go struct field Hero.Name: embedded field of non-struct type graphql.Name must have a graphql tag")
+	})
+	t.Run("ResponseKeys", func(t *testing.T) {
+		type Fields struct {
+			Name string
+		}
+		var q struct {
+			Hero struct {
+				Name     string
+				FullName string `graphql:"name"`
+				Fields
+				Droid struct {
+					Name string
+				} `graphql:"... on Droid"`
+				Small string `graphql:"pic: profilePic(size: 64)"`
+				Large string `graphql:"pic: profilePic(size: 256)"`
+			}
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, "error at Go struct field Hero.FullName: Go struct fields Hero.Name and Hero.FullName both "+
+			"map to response key \"name\"\n"+
+			"error at Go struct field Hero.Fields.Name: Go struct fields Hero.Name and Hero.Fields.Name both map to response "+
+			"key \"name\"\n"+
+			"error at Go struct field Hero.Large: Go struct fields Hero.Small and Hero.Large both map to response key \"pic\"")
+	})
+	t.Run("ResponseKeysCase", func(t *testing.T) {
+		var q struct {
+			Repo struct {
+				URL     string `graphql:"URL"`
+				Link    string `graphql:"url"`
+				UserID  string
+				OwnerID string `graphql:"userID: ownerId"`
+			}
+		}
+		_, err := ConstructQuery(&q, nil)
+		assert.EqualError(t, err, "error at Go struct field Repo.Link: Go struct fields Repo.URL and Repo.Link map to "+
+			"response keys \"URL\" and \"url\", which only differ in case\n"+
+			"error at Go struct field Repo.OwnerID: Go struct fields Repo.UserID and Repo.OwnerID map to response keys "+
+			"\"userId\" and \"userID\", which only differ in case")
+	})
+}
+
+func Test_queryBuilder_typename(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		type HumanFragment struct {
+			Height float64
+		}
+		type Hero struct {
+			HumanFragment `graphql:"... on Human"`
+		}
+		type EmailFields struct {
+			Email string
+		}
+		var q struct {
+			Hero struct {
+				Hero
+				Name  string
+				Droid struct {
+					PrimaryFunction string
+				} `graphql:"... on Droid"`
+			}
+			Search []struct {
+				Typename string `graphql:"__typename"`
+				Repo     struct {
+					Stars int
+				} `graphql:"... on Repo"`
+			}
+			Viewer struct {
+				Login       string
+				EmailFields `include:"withEmails"`
+			}
+		}
+		actual, err := ConstructQuery(&q, map[string]any{"withEmails": true})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "query($withEmails:Boolean!){hero{__typename,... on Human{height}name,... on Droid{primaryFunction}}"+
+				"search{__typename,... on Repo{stars}}viewer{login,... @include(if: $withEmails){email}}}", actual)
+		}
+	})
+	t.Run("SingleTypeCondition", func(t *testing.T) {
+		var q struct {
+			Hero struct {
+				Name  string
+				Droid struct {
+					PrimaryFunction string
+				} `graphql:"... on Droid"`
+			}
+		}
+		actual, err := ConstructQuery(&q, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "query{hero{name,... on Droid{primaryFunction}}}", actual)
+		}
+		actual, err = ConstructQuery(&q, nil, WithPossibleTypes(map[string][]string{"Character": {"Droid", "Human"}}))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "query{hero{__typename,name,... on Droid{primaryFunction}}}", actual)
+		}
+	})
+	t.Run("Unmarshal", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"search":[{"__typename":"User","name":"a"},{"__typename":"Repo","name":"b","stars":1}]}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport},
+			WithPossibleTypes(map[string][]string{"Node": {"User", "Repo"}}))
+		var q struct {
+			Search []struct {
+				Node struct {
+					Name string
+				} `graphql:"... on Node"`
+				User *struct {
+					Name string
+				} `graphql:"... on User"`
+				Repo *struct {
+					Name  string
+					Stars int
+				} `graphql:"... on Repo"`
+			}
+		}
+		_, err := c.Query(context.Background(), &q, nil)
+		if assert.NoError(t, err) && assert.Len(t, q.Search, 2) {
+			assert.Equal(t, "a", q.Search[0].Node.Name)
+			if assert.NotNil(t, q.Search[0].User) {
+				assert.Equal(t, "a", q.Search[0].User.Name)
+			}
+			assert.Nil(t, q.Search[0].Repo)
+			assert.Equal(t, "b", q.Search[1].Node.Name)
+			assert.Nil(t, q.Search[1].User)
+			if assert.NotNil(t, q.Search[1].Repo) {
+				assert.Equal(t, "b", q.Search[1].Repo.Name)
+				assert.Equal(t, 1, q.Search[1].Repo.Stars)
+			}
+		}
+	})
+}
+
+type testGeoJSON struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (g *testGeoJSON) UnmarshalJSON(b []byte) error {
+	g.Raw = append(g.Raw[:0], b...)
+	return json.Unmarshal(b, &struct{ Type *string }{&g.Type})
+}
+
+type testColor struct {
+	R, G, B uint8
+}
+
+func (c *testColor) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return err
+}
+
+func Test_Client_unmarshalers(t *testing.T) {
+	transport := &testTransport{
+		RespBody: []byte(`{"data":{"node":{"bounds":{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,1],[0,0]]]},` +
+			`"color":"#0000ff"}}}`),
+	}
+	c := NewClient("http://localhost/graphql", &http.Client{Transport: transport})
+	var q struct {
+		Node struct {
+			Bounds *testGeoJSON
+			Color  testColor
+		}
+	}
+	_, err := c.Query(context.Background(), &q, nil)
+	if assert.NoError(t, err) && assert.NotNil(t, q.Node.Bounds) {
+		assert.Equal(t, "Polygon", q.Node.Bounds.Type)
+		assert.Equal(t, `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,1],[0,0]]]}`, string(q.Node.Bounds.Raw))
+		assert.Equal(t, testColor{B: 0xff}, q.Node.Color)
+	}
+}
+
+func Test_queryBuilder_select(t *testing.T) {
+	type Query struct {
+		Viewer struct {
+			Login    string
+			Settings json.RawMessage `graphql:"settings" select:"{theme locale(format: $format)}"`
+			Profile  map[string]any  `select:"{bio links{url}}"`
+			Extra    any             `graphql:"extra" select:"{a b}"`
+		}
+	}
+	t.Run("ConstructQuery", func(t *testing.T) {
+		actual, err := ConstructQuery(&Query{}, map[string]any{"format": "BCP47"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($format:String!){viewer{login,settings{theme,locale(format:$format)}`+
+				`profile{bio,links{url}}extra{a,b}}}`, actual)
+		}
+		actual, err = ConstructQuery(&Query{}, map[string]any{"format": "BCP47"}, WithIndent("  "))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query ($format: String!) {
+  viewer {
+    login
+    settings {
+      theme
+      locale(format: $format)
+    }
+    profile {
+      bio
+      links {
+        url
+      }
+    }
+    extra {
+      a
+      b
+    }
+  }
+}`, actual)
+		}
+	})
+	t.Run("UndefinedVariable", func(t *testing.T) {
+		_, err := ConstructQuery(&Query{}, nil)
+		assert.EqualError(t, err, `undefined variables: $format (used by Go struct field Viewer.Settings)`)
+	})
+	t.Run("InlineFragmentsAndDirectives", func(t *testing.T) {
+		var q struct {
+			Node json.RawMessage `graphql:"node(id: $id)" select:"{id ... on User @include(if: $withUser) {n: name}}"`
+		}
+		actual, err := ConstructQuery(&q, map[string]any{"id": "1", "withUser": true})
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($id:String!,$withUser:Boolean!){node(id: $id){id,... on User @include(if:$withUser)`+
+				`{n:name}}}`, actual)
+		}
+	})
+	t.Run("Unmarshal", func(t *testing.T) {
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"viewer":{"login":"henk","settings":{"theme":"dark","locale":"nl"},` +
+				`"profile":{"bio":"Hi","links":[{"url":"https://example.com"}]},"extra":{"a":1,"b":[true]}}}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport})
+		var q Query
+		_, err := c.Query(context.Background(), &q, map[string]any{"format": "BCP47"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "henk", q.Viewer.Login)
+			assert.Equal(t, `{"theme":"dark","locale":"nl"}`, string(q.Viewer.Settings))
+			assert.Equal(t, map[string]any{"bio": "Hi", "links": []any{map[string]any{"url": "https://example.com"}}},
+				q.Viewer.Profile)
+			assert.Equal(t, map[string]any{"a": float64(1), "b": []any{true}}, q.Viewer.Extra)
+		}
 	})
 }
@@ -0,0 +1,180 @@
+package graphql
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	internalJSON "github.com/jbrekelmans/go-graphql/json"
+)
+
+// Scalar defines how values of a Go type map to a custom GraphQL scalar.
+type Scalar struct {
+	// Name is the name of the GraphQL scalar, e.g. "DateTime".
+	Name string
+
+	// Marshal encodes a value of the Go type as JSON. "encoding/json".Marshal is used if Marshal is nil.
+	Marshal func(v any) ([]byte, error)
+
+	// Unmarshal decodes JSON into v, which is a non-nil pointer to a value of the Go type. Unmarshal is never called with
+	// the JSON value null. "encoding/json".Unmarshal is used if Unmarshal is nil.
+	Unmarshal func(b []byte, v any) error
+}
+
+// ScalarRegistry maps Go types to custom GraphQL scalars. Values of registered Go types are:
+//   - declared with the name of the scalar when used as variable values (e.g. "$since:DateTime!");
+//   - marshaled with Scalar.Marshal when used as variable values; and
+//   - selected as leaf fields (i.e. without selection set) and unmarshaled as a whole with Scalar.Unmarshal, even if their
+//     JSON values are objects or arrays.
+//
+// A ScalarRegistry must not be modified after it is passed to NewClient or another function of this package.
+type ScalarRegistry struct {
+	scalars map[reflect.Type]Scalar
+}
+
+// NewScalarRegistry creates an empty registry. See DefaultScalarRegistry for a registry with sensible defaults.
+func NewScalarRegistry() *ScalarRegistry {
+	return &ScalarRegistry{
+		scalars: map[reflect.Type]Scalar{},
+	}
+}
+
+// DefaultScalarRegistry creates a registry with the following mappings, which is used by clients unless WithScalars is
+// passed:
+//   - time.Time maps to DateTime (RFC 3339 strings);
+//   - big.Int maps to BigInt (JSON numbers, or strings when unmarshaling);
+//   - json.RawMessage maps to JSON; and
+//   - [16]byte maps to UUID (strings like "123e4567-e89b-12d3-a456-426614174000").
+//
+// Named types with underlying type [16]byte (such as UUID types of third-party packages) must be registered explicitly, for
+// example with RegisterScalar[uuid.UUID](r, graphql.UUIDScalar). Likewise, map types are not registered because many
+// schemas have no JSON scalar, but can be, e.g. with RegisterScalar[map[string]any](r, graphql.Scalar{Name: "JSON"}).
+func DefaultScalarRegistry() *ScalarRegistry {
+	r := NewScalarRegistry()
+	RegisterScalar[time.Time](r, Scalar{Name: "DateTime"})
+	RegisterScalar[big.Int](r, Scalar{
+		Name:      "BigInt",
+		Unmarshal: unmarshalBigInt,
+	})
+	RegisterScalar[json.RawMessage](r, Scalar{Name: "JSON"})
+	RegisterScalar[[16]byte](r, UUIDScalar)
+	return r
+}
+
+var defaultScalars = DefaultScalarRegistry()
+
+// UUIDScalar maps Go types with underlying type [16]byte to the UUID scalar.
+// Values are marshaled as strings like "123e4567-e89b-12d3-a456-426614174000", and strings are unmarshaled with or without
+// hyphens.
+var UUIDScalar = Scalar{
+	Name:      "UUID",
+	Marshal:   marshalUUID,
+	Unmarshal: unmarshalUUID,
+}
+
+// Register registers Go type t. Pointers to t are handled as well, so t should not be a pointer type.
+func (r *ScalarRegistry) Register(t reflect.Type, s Scalar) {
+	r.scalars[t] = s
+}
+
+// RegisterScalar registers Go type T. See (*ScalarRegistry).Register.
+func RegisterScalar[T any](r *ScalarRegistry, s Scalar) {
+	r.Register(reflect.TypeOf((*T)(nil)).Elem(), s)
+}
+
+// Lookup returns the scalar that Go type t maps to.
+func (r *ScalarRegistry) Lookup(t reflect.Type) (Scalar, bool) {
+	if r == nil {
+		return Scalar{}, false
+	}
+	s, ok := r.scalars[t]
+	return s, ok
+}
+
+// IsScalar implements the json.ScalarTypes interface.
+func (r *ScalarRegistry) IsScalar(t reflect.Type) bool {
+	_, ok := r.Lookup(t)
+	return ok
+}
+
+// UnmarshalScalar implements the json.ScalarTypes interface.
+func (r *ScalarRegistry) UnmarshalScalar(b []byte, v any) error {
+	s, _ := r.Lookup(reflect.TypeOf(v).Elem())
+	if s.Unmarshal == nil {
+		return json.Unmarshal(b, v)
+	}
+	return s.Unmarshal(b, v)
+}
+
+var _ internalJSON.ScalarTypes = (*ScalarRegistry)(nil)
+
+// marshal marshals v, whose type is registered.
+func (r *ScalarRegistry) marshal(v reflect.Value) ([]byte, error) {
+	s, _ := r.Lookup(v.Type())
+	if s.Marshal == nil {
+		return json.Marshal(v.Interface())
+	}
+	return s.Marshal(v.Interface())
+}
+
+// WithScalars configures the mapping of Go types to custom GraphQL scalars. See ScalarRegistry.
+func WithScalars(r *ScalarRegistry) Option {
+	return func(o *options) {
+		o.scalars = r
+	}
+}
+
+func unmarshalBigInt(b []byte, v any) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		b = []byte(s)
+	}
+	return json.Unmarshal(b, v)
+}
+
+func marshalUUID(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	var u [16]byte
+	reflect.Copy(reflect.ValueOf(u[:]), rv)
+	var buf [38]byte
+	buf[0] = '"'
+	hex.Encode(buf[1:9], u[:4])
+	buf[9] = '-'
+	hex.Encode(buf[10:14], u[4:6])
+	buf[14] = '-'
+	hex.Encode(buf[15:19], u[6:8])
+	buf[19] = '-'
+	hex.Encode(buf[20:24], u[8:10])
+	buf[24] = '-'
+	hex.Encode(buf[25:37], u[10:])
+	buf[37] = '"'
+	return buf[:], nil
+}
+
+func unmarshalUUID(b []byte, v any) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	var hexDigits []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '-' {
+			hexDigits = append(hexDigits, s[i])
+		}
+	}
+	var u [16]byte
+	if len(hexDigits) != 2*len(u) {
+		return fmt.Errorf(`invalid UUID %#v`, s)
+	}
+	if _, err := hex.Decode(u[:], hexDigits); err != nil {
+		return fmt.Errorf(`invalid UUID %#v: %w`, s, err)
+	}
+	reflect.Copy(reflect.ValueOf(v).Elem(), reflect.ValueOf(u[:]))
+	return nil
+}
@@ -0,0 +1,98 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUUID [16]byte
+
+type testMoney struct {
+	Amount   string
+	Currency string
+}
+
+func Test_ScalarRegistry(t *testing.T) {
+	t.Run("ConstructQuery", func(t *testing.T) {
+		var q struct {
+			Events []struct {
+				At       time.Time
+				Payload  json.RawMessage
+				Metadata map[string]any
+			} `graphql:"events(since: $since, ids: $ids, filter: $filter)"`
+		}
+		r := DefaultScalarRegistry()
+		RegisterScalar[map[string]any](r, Scalar{Name: "JSON"})
+		actual, err := ConstructQuery(&q, map[string]any{
+			"since":  time.Time{},
+			"ids":    []*[16]byte{},
+			"filter": map[string]any{},
+		}, WithScalars(r))
+		if assert.NoError(t, err) {
+			assert.Equal(t, `query($filter:JSON!,$ids:[UUID]!,$since:DateTime!){events(since: $since, ids: $ids, `+
+				`filter: $filter){at,payload,metadata}}`, actual)
+		}
+	})
+	t.Run("Client", func(t *testing.T) {
+		r := DefaultScalarRegistry()
+		RegisterScalar[testUUID](r, UUIDScalar)
+		RegisterScalar[testMoney](r, Scalar{
+			Name: "Money",
+			Unmarshal: func(b []byte, v any) error {
+				var s string
+				if err := json.Unmarshal(b, &s); err != nil {
+					return err
+				}
+				amount, currency, _ := strings.Cut(s, " ")
+				*v.(*testMoney) = testMoney{Amount: amount, Currency: currency}
+				return nil
+			},
+		})
+		transport := &testTransport{
+			RespBody: []byte(`{"data":{"order":{"id":"123e4567-e89b-12d3-a456-426614174000","total":"1.50 EUR",` +
+				`"count":"12345678901234567890","meta":{"a":[1]}}}}`),
+		}
+		c := NewClient("http://localhost/graphql", &http.Client{Transport: transport}, WithScalars(r))
+		var q struct {
+			Order struct {
+				ID    testUUID
+				Total testMoney
+				Count *big.Int
+				Meta  json.RawMessage
+			} `graphql:"order(id: $id)"`
+		}
+		id := testUUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+		_, err := c.Query(context.Background(), &q, map[string]any{"id": id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, id, q.Order.ID)
+			assert.Equal(t, testMoney{Amount: "1.50", Currency: "EUR"}, q.Order.Total)
+			assert.Equal(t, "12345678901234567890", q.Order.Count.String())
+			assert.Equal(t, `{"a":[1]}`, string(q.Order.Meta))
+			reqBody, err := io.ReadAll(transport.Req.Body)
+			if assert.NoError(t, err) {
+				assert.Equal(t, `{"query":"query($id:UUID!){order(id: $id){id,total,count,meta}}",`+
+					`"variables":{"id":"123e4567-e89b-12d3-a456-426614174000"}}`, string(reqBody))
+			}
+		}
+	})
+	t.Run("UnmarshalUUID", func(t *testing.T) {
+		var u [16]byte
+		err := unmarshalUUID([]byte(`"123e4567e89b12d3a456426614174000"`), &u)
+		if assert.NoError(t, err) {
+			b, err := marshalUUID(u)
+			if assert.NoError(t, err) {
+				assert.Equal(t, `"123e4567-e89b-12d3-a456-426614174000"`, string(b))
+			}
+		}
+		err = unmarshalUUID([]byte(`"123"`), &u)
+		assert.EqualError(t, err, `invalid UUID "123"`)
+	})
+}
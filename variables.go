@@ -0,0 +1,388 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/graphql/ident"
+
+	"github.com/jbrekelmans/go-graphql/mapping"
+)
+
+// GraphQLTyper is implemented by Go types of variable values that declare the GraphQL type of the variable.
+// See Var for declaring the GraphQL type of a single variable.
+//
+// If a Go type T implements GraphQLTyper then GraphQLType is called on the zero value of T when a GraphQL type is derived
+// from T, for example for the elements of a slice of T. Pointers to T make the type nullable (i.e. a trailing "!" is
+// removed). GraphQLType is called on the actual value if a variable value implements GraphQLTyper, in which case the
+// result is used verbatim.
+type GraphQLTyper interface {
+	// GraphQLType returns the GraphQL type, e.g. "DateTime!" or "[ID!]". The GraphQL type is derived from the Go type as
+	// usual if GraphQLType returns an empty string.
+	GraphQLType() string
+}
+
+// Var is a variable value with an explicit GraphQL type. For example:
+//
+//	variables := map[string]any{
+//		"since": graphql.Var[time.Time]{Type: "DateTime!", Value: since},
+//	}
+//
+// Var marshals to JSON like Value.
+type Var[T any] struct {
+	// Type is the GraphQL type of the variable, e.g. "DateTime!". The GraphQL type is derived from T if Type is empty.
+	Type string
+
+	// Value is the value of the variable.
+	Value T
+
+	// Default is the default value of the variable, which is declared in the variable definition as a GraphQL literal, e.g.
+	// "$first:Int=20". The variable has no default value if Default is absent (the zero value).
+	Default Omittable[T]
+}
+
+var _ GraphQLTyper = Var[int]{}
+
+// GraphQLType implements the GraphQLTyper interface.
+func (v Var[T]) GraphQLType() string {
+	return v.Type
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v Var[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value)
+}
+
+func (Var[T]) varValueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (v Var[T]) value() any {
+	return v.Value
+}
+
+func (v Var[T]) defaultValue() (any, bool) {
+	return v.Default.omittableValue()
+}
+
+// varValue is implemented by all instantiations of Var.
+type varValue interface {
+	varValueType() reflect.Type
+	value() any
+	// defaultValue returns the default value (nil if it is null), and whether there is a default value.
+	defaultValue() (any, bool)
+}
+
+var (
+	graphQLTyperType = reflect.TypeOf((*GraphQLTyper)(nil)).Elem()
+	varValueType     = reflect.TypeOf((*varValue)(nil)).Elem()
+)
+
+// variableType returns the GraphQL type of a variable with value v.
+func variableType(v any, scalars *ScalarRegistry) (string, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		// Do not call methods on nil pointers. The GraphQL type is derived from the pointer type instead.
+		return graphQLType(rv.Type(), scalars)
+	}
+	if typer, ok := v.(GraphQLTyper); ok {
+		if typeName := typer.GraphQLType(); typeName != "" {
+			return typeName, nil
+		}
+	}
+	if v, ok := v.(varValue); ok {
+		return graphQLType(v.varValueType(), scalars)
+	}
+	if v == nil {
+		return "", fmt.Errorf(`cannot derive GraphQL type from nil value (use graphql.Var to declare the type)`)
+	}
+	return graphQLType(reflect.TypeOf(v), scalars)
+}
+
+// graphQLType derives a GraphQL type from Go type t.
+func graphQLType(t reflect.Type, scalars *ScalarRegistry) (string, error) {
+	nonNull := true
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nonNull = false
+	}
+	if t.Implements(varValueType) && t.Kind() != reflect.Interface {
+		return graphQLType(reflect.Zero(t).Interface().(varValue).varValueType(), scalars)
+	}
+	if t.Implements(omittableType) && t.Kind() != reflect.Interface {
+		typeName, err := graphQLType(reflect.Zero(t).Interface().(omittable).omittableValueType(), scalars)
+		if err != nil {
+			return "", err
+		}
+		// An Omittable can be null.
+		return strings.TrimSuffix(typeName, "!"), nil
+	}
+	var typeName string
+	if t.Implements(graphQLTyperType) && t.Kind() != reflect.Interface {
+		typeName = reflect.Zero(t).Interface().(GraphQLTyper).GraphQLType()
+	}
+	if scalar, ok := scalars.Lookup(t); ok && typeName == "" {
+		typeName = scalar.Name + "!"
+	}
+	if typeName != "" {
+		if !nonNull && typeName[len(typeName)-1] == '!' {
+			typeName = typeName[:len(typeName)-1]
+		}
+		return typeName, nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elemTypeName, err := graphQLType(t.Elem(), scalars)
+		if err != nil {
+			return "", err
+		}
+		typeName = "[" + elemTypeName + "]"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		typeName = "Int"
+	case reflect.Uint64, reflect.Uintptr:
+		// Values of these types (e.g. hashes and IDs) are typically not numbers in the schema.
+		return "", fmt.Errorf(`cannot derive GraphQL type from Go type %v (implement graphql.GraphQLTyper or use `+
+			`graphql.Var to declare the type)`, t)
+	case reflect.String:
+		typeName = "String"
+	case reflect.Float32, reflect.Float64:
+		typeName = "Float"
+	case reflect.Bool:
+		typeName = "Boolean"
+	default:
+		typeName = t.Name()
+		if !mapping.IsName(typeName) {
+			// For example, anonymous structs, maps, interfaces and instantiations of generic types.
+			return "", fmt.Errorf(`cannot derive GraphQL type from Go type %v (implement graphql.GraphQLTyper or use `+
+				`graphql.Var to declare the type)`, t)
+		}
+	}
+	if nonNull {
+		typeName += "!"
+	}
+	return typeName, nil
+}
+
+// variable is a variable definition and its value.
+type variable struct {
+	name string
+	// typeName is the GraphQL type of the variable, or empty if it should be derived from value.
+	typeName string
+	// defaultValue is the GraphQL literal of the default value of the variable as declared by a struct tag, or empty.
+	defaultValue string
+	value        any
+}
+
+// newVariables normalizes variables, which is nil, a map with string keys or a (pointer to a) struct, to a list of
+// variables. The variables of a map are sorted by name so documents are deterministic, and the variables of a struct are
+// in field order.
+func newVariables(variables any) ([]variable, error) {
+	rv := reflect.ValueOf(variables)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid():
+		return nil, nil
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		vars := make([]variable, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			vars = append(vars, variable{
+				name:  iter.Key().String(),
+				value: iter.Value().Interface(),
+			})
+		}
+		sort.Slice(vars, func(i, j int) bool {
+			return vars[i].name < vars[j].name
+		})
+		return vars, nil
+	case rv.Kind() == reflect.Struct:
+		var vars []variable
+		if err := appendStructVariables(&vars, rv); err != nil {
+			return nil, err
+		}
+		return vars, nil
+	}
+	return nil, fmt.Errorf(`invalid variables type %T (want a map with string keys or a struct)`, variables)
+}
+
+// appendStructVariables appends the variables defined by the fields of struct value rv. The graphql tag of a field can
+// define the name of the variable, and optionally its GraphQL type and default value (as a GraphQL literal), e.g.
+// `graphql:"first"`, `graphql:"first:Int"` or `graphql:"first:Int=20"`.
+// The name defaults to the field name in lowerCamelCase, fields with tag `graphql:"-"` are skipped, and embedded structs
+// without tag are inlined. Returns a *mapping.TagError if the type or default value of a tag is not valid GraphQL.
+func appendStructVariables(vars *[]variable, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !(f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			// Like encoding/json, embedded structs of unexported types are inlined.
+			continue
+		}
+		tag, hasTag := f.Tag.Lookup("graphql")
+		if tag == "-" {
+			continue
+		}
+		fieldValue := rv.Field(i)
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			if err := appendStructVariables(vars, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+		v := variable{
+			name:  ident.ParseMixedCaps(f.Name).ToLowerCamelCase(),
+			value: fieldValue.Interface(),
+		}
+		if hasTag {
+			nameAndType, defaultValue, hasDefault := strings.Cut(tag, "=")
+			name, typeName, hasType := strings.Cut(nameAndType, ":")
+			v.name = strings.TrimPrefix(strings.TrimSpace(name), "$")
+			v.typeName = strings.TrimSpace(typeName)
+			v.defaultValue = strings.TrimSpace(defaultValue)
+			var err error
+			if hasType {
+				_, err = mapping.ParseType(v.typeName)
+				if err != nil {
+					err = fmt.Errorf(`invalid type %#v: %w`, v.typeName, err)
+				}
+			}
+			if err == nil && hasDefault {
+				_, err = mapping.ParseConstValue(v.defaultValue)
+				if err != nil {
+					err = fmt.Errorf(`invalid default value %#v: %w`, v.defaultValue, err)
+				}
+			}
+			if err != nil {
+				return &mapping.TagError{Type: t, Field: f.Name, Key: "graphql", Tag: tag, Err: err}
+			}
+		}
+		if !mapping.IsName(v.name) {
+			return fmt.Errorf(`invalid variable name %#v of Go struct field %s`, v.name, f.Name)
+		}
+		*vars = append(*vars, v)
+	}
+	return nil
+}
+
+// lookupVariable returns the variable named name.
+func lookupVariable(vars []variable, name string) (variable, bool) {
+	for _, v := range vars {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return variable{}, false
+}
+
+// graphQLType returns the GraphQL type of v.
+func (v variable) graphQLType(scalars *ScalarRegistry) (string, error) {
+	if v.typeName != "" {
+		return v.typeName, nil
+	}
+	return variableType(v.value, scalars)
+}
+
+// defaultLiteral returns the GraphQL literal of the default value of v, which is declared by a struct tag or by Var.Default,
+// or an empty string if v has no default value.
+func (v variable) defaultLiteral(scalars *ScalarRegistry) (string, error) {
+	if v.defaultValue != "" {
+		return v.defaultValue, nil
+	}
+	if value, ok := v.value.(varValue); ok {
+		if d, ok := value.defaultValue(); ok {
+			return literal(d, scalars)
+		}
+	}
+	return "", nil
+}
+
+// hasDefault returns true if v has a default value declared by a struct tag or by Var.Default.
+func (v variable) hasDefault() bool {
+	if v.defaultValue != "" {
+		return true
+	}
+	if value, ok := v.value.(varValue); ok {
+		_, ok = value.defaultValue()
+		return ok
+	}
+	return false
+}
+
+// omitted returns true if the value of v is left out of the variables sent to the server. Absent Omittable values are
+// always left out. If v has a default value then nil values (such as nil pointers) are left out as well, so that the
+// server uses the default value: an explicit null would override it. Zero values such as 0 are sent. Use an Omittable to
+// send null explicitly.
+func (v variable) omitted() bool {
+	rv := reflect.ValueOf(v.value)
+	if isAbsent(rv) {
+		return true
+	}
+	if !v.hasDefault() {
+		return false
+	}
+	if value, ok := v.value.(varValue); ok {
+		rv = reflect.ValueOf(value.value())
+		if isAbsent(rv) {
+			return true
+		}
+	}
+	switch {
+	case !rv.IsValid():
+		return true
+	case rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func (qb *queryBuilder) varDefs(vars []variable) error {
+	// https://spec.graphql.org/October2021/#VariableDefinitions
+	if len(vars) == 0 {
+		return nil
+	}
+	if qb.indent != "" {
+		qb.b.WriteByte(' ')
+	}
+	qb.b.WriteByte('(')
+	for i, v := range vars {
+		typeName, err := v.graphQLType(qb.scalars)
+		if err != nil {
+			return fmt.Errorf(`variable $%s: %w`, v.name, err)
+		}
+		defaultValue, err := v.defaultLiteral(qb.scalars)
+		if err != nil {
+			return fmt.Errorf(`default value of variable $%s: %w`, v.name, err)
+		}
+		if i > 0 {
+			qb.b.WriteByte(',')
+			if qb.indent != "" {
+				qb.b.WriteByte(' ')
+			}
+		}
+		qb.b.WriteByte('$')
+		qb.raw(v.name)
+		qb.b.WriteByte(':')
+		if qb.indent != "" {
+			qb.b.WriteByte(' ')
+		}
+		qb.raw(typeName)
+		if defaultValue != "" {
+			if qb.indent != "" {
+				qb.raw(" = ")
+			} else {
+				qb.b.WriteByte('=')
+			}
+			qb.raw(defaultValue)
+		}
+	}
+	qb.b.WriteByte(')')
+	return nil
+}
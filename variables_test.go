@@ -0,0 +1,177 @@
+package graphql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEpisode string
+
+func (testEpisode) GraphQLType() string {
+	return "Episode!"
+}
+
+type testGeneric[T any] struct {
+	Value T
+}
+
+func Test_graphQLType(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual, err := graphQLType(reflect.TypeOf([]*testEpisode{}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "[Episode]!", actual)
+		}
+	})
+	t.Run("Case2", func(t *testing.T) {
+		actual, err := graphQLType(reflect.TypeOf([]Var[int]{}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "[Int!]!", actual)
+		}
+	})
+	t.Run("Case3", func(t *testing.T) {
+		_, err := graphQLType(reflect.TypeOf(uint64(0)), nil)
+		assert.ErrorContains(t, err, "cannot derive GraphQL type from Go type uint64 (implement graphql.GraphQLTyper")
+		actual, err := graphQLType(reflect.TypeOf([]uint32{}), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "[Int!]!", actual)
+		}
+	})
+	t.Run("Case4", func(t *testing.T) {
+		_, err := graphQLType(reflect.TypeOf(map[string]any{}), nil)
+		assert.ErrorContains(t, err, "cannot derive GraphQL type from Go type map[string]interface {}")
+	})
+	t.Run("Case5", func(t *testing.T) {
+		_, err := graphQLType(reflect.TypeOf(struct{ A int }{}), nil)
+		assert.ErrorContains(t, err, "cannot derive GraphQL type from Go type struct { A int }")
+	})
+	t.Run("Case6", func(t *testing.T) {
+		_, err := graphQLType(reflect.TypeOf(testGeneric[int]{}), nil)
+		assert.ErrorContains(t, err, "cannot derive GraphQL type from Go type graphql.testGeneric[int]")
+	})
+}
+
+func Test_variableType(t *testing.T) {
+	t.Run("Var", func(t *testing.T) {
+		actual, err := variableType(Var[time.Time]{Type: "DateTime!", Value: time.Now()}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "DateTime!", actual)
+		}
+	})
+	t.Run("VarWithoutType", func(t *testing.T) {
+		actual, err := variableType(Var[*string]{}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "String", actual)
+		}
+	})
+	t.Run("Nil", func(t *testing.T) {
+		_, err := variableType(nil, nil)
+		assert.ErrorContains(t, err, "cannot derive GraphQL type from nil value")
+	})
+	t.Run("NilPointer", func(t *testing.T) {
+		actual, err := variableType((*testEpisode)(nil), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Episode", actual)
+		}
+		var q struct {
+			Hero struct {
+				Name string
+			} `graphql:"hero(episode: $ep)"`
+		}
+		actual, err = ConstructQuery(&q, map[string]any{"ep": (*testEpisode)(nil)})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "query($ep:Episode){hero(episode: $ep){name}}", actual)
+		}
+	})
+}
+
+func Test_Var(t *testing.T) {
+	t.Run("MarshalJSON", func(t *testing.T) {
+		b, err := Var[[]int]{Type: "[Int!]", Value: []int{1}}.MarshalJSON()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "[1]", string(b))
+		}
+	})
+	t.Run("ConstructQuery", func(t *testing.T) {
+		var q struct {
+			Events []struct {
+				Name string
+			} `graphql:"events(since: $since)"`
+		}
+		_, err := ConstructQuery(&q, map[string]any{"since": map[string]int{}})
+		assert.EqualError(t, err, "variable $since: cannot derive GraphQL type from Go type map[string]int "+
+			"(implement graphql.GraphQLTyper or use graphql.Var to declare the type)")
+	})
+}
+
+func mustNewVariables(t *testing.T, variables any) []variable {
+	t.Helper()
+	vars, err := newVariables(variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vars
+}
+
+type testVariablesEmbedded struct {
+	After *string
+}
+
+func Test_newVariables(t *testing.T) {
+	t.Run("Case1", func(t *testing.T) {
+		actual, err := newVariables(nil)
+		if assert.NoError(t, err) {
+			assert.Empty(t, actual)
+		}
+	})
+	t.Run("Case2", func(t *testing.T) {
+		actual, err := newVariables(map[string]int{"b": 2, "a": 1})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []variable{{name: "a", value: 1}, {name: "b", value: 2}}, actual)
+		}
+	})
+	t.Run("Case3", func(t *testing.T) {
+		actual, err := newVariables(&struct {
+			RepositoryOwner string
+			First           int  `graphql:"$count:Int"`
+			Ignored         bool `graphql:"-"`
+			unexported      bool
+			testVariablesEmbedded
+		}{RepositoryOwner: "x", First: 3})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []variable{
+				{name: "repositoryOwner", value: "x"},
+				{name: "count", typeName: "Int", value: 3},
+				{name: "after", value: (*string)(nil)},
+			}, actual)
+		}
+	})
+	t.Run("Case4", func(t *testing.T) {
+		_, err := newVariables([]string{"a"})
+		assert.EqualError(t, err, "invalid variables type []string (want a map with string keys or a struct)")
+	})
+	t.Run("Case5", func(t *testing.T) {
+		_, err := newVariables(struct {
+			A int `graphql:"1a"`
+		}{})
+		assert.EqualError(t, err, `invalid variable name "1a" of Go struct field A`)
+	})
+	t.Run("InvalidTags", func(t *testing.T) {
+		_, err := newVariables(struct {
+			First int `graphql:"first:Int=abc def ("`
+		}{})
+		assert.EqualError(t, err, `invalid graphql tag "first:Int=abc def (" of Go struct field struct { First int `+
+			`"graphql:\"first:Int=abc def (\"" }.First: invalid default value "abc def (": unexpected "def" at offset 4`)
+		_, err = newVariables(struct {
+			First int `graphql:"first:[Int"`
+		}{})
+		assert.EqualError(t, err, `invalid graphql tag "first:[Int" of Go struct field struct { First int `+
+			`"graphql:\"first:[Int\"" }.First: invalid type "[Int": unexpected end of tag at offset 4`)
+		_, err = newVariables(struct {
+			Unit string `graphql:"unit:LengthUnit=$unit"`
+		}{})
+		assert.ErrorContains(t, err, `invalid default value "$unit": variables are not allowed in constant values`)
+	})
+}